@@ -2,7 +2,6 @@ package main
 
 import (
 	"encoding/json"
-	"fmt"
 	"io"
 	"io/ioutil"
 	"net/http"
@@ -20,39 +19,51 @@ func GameStateAPI(w http.ResponseWriter, r *http.Request) {
 			"blackFilled":[[3,3],[4,4]],    // Positions on board filled with black pieces
 			"whiteFilled":[[3,4],[4,3]],    // Positions on board filled with white piece
 			"turn":1,                       // Agent's turn to play as (1 black, -1 white)
+			"boardSize":8,                  // Optional, any even size from 4 to 16
+			"variant":"standard",           // Optional, "misere" for fewer pieces to win
+			"blocked":[[0,0]],              // Optional, squares no piece can be placed on
+			"policy":"visits",              // Optional, final move policy of the agent
+		}
+	or as a single-line position string (64 squares and side to move):
+		{
+			"position":"---------------------------OX------XO--------------------------- X"
 		}
 	Response JSON example:
 		{
 			"move":[3,2],                   // The move the agent is going to make
 			"turn":1,                       // The turn
 			"blackScore":1,
-			"whiteScore":4
+			"whiteScore":4,
+			"policy":"heuristic"            // The policy that selected the move
 		}
 	*/
-	var state = GameState{}
 	body, err := ioutil.ReadAll(io.LimitReader(r.Body, 1048576))
 
 	if err != nil {
 		panic(err)
 	}
-	err = json.Unmarshal(body, &state) //Converts request body to moveArr type
+	state, game, err := decodeGameState(body)
 	if err != nil {
-		fmt.Println("error:", err)
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
 	}
-	game := SetGame(state)
+	colour := game.turn
 	root := Node{
-		state: game,
+		state: game.compact(),
 		depth: 0,
 	}
-	decision := Search(root, 20, 300)
+	p := DefaultParams
+	p.FinalPolicy = state.Policy
+	decision, policy := SearchDecision(root, 20, 300, p)
 	game.Move(decision)
 
 	response := DecisionResponse{
 		Move:       [2]int{decision.i, decision.j},
-		Colour:     state.Turn,
+		Colour:     colour,
 		Turn:       game.turn,
 		BlackScore: game.blackScore,
 		WhiteScore: game.whiteScore,
+		Policy:     policy,
 	}
 
 	//Allow CORS here By * or specific origin
@@ -60,3 +71,59 @@ func GameStateAPI(w http.ResponseWriter, r *http.Request) {
 	json.NewEncoder(w).Encode(response)
 
 }
+
+func decodeGameState(body []byte) (GameState, Board, error) {
+	// Decode the JSON game state posted to /search_move and setup its board
+	// Errors are the client's, the board has a valid move if there are none
+	state := GameState{}
+	if err := json.Unmarshal(body, &state); err != nil {
+		return state, Board{}, err
+	}
+	game, err := LoadGame(state)
+	return state, game, err
+}
+
+func NewGameAPI(w http.ResponseWriter, r *http.Request) {
+	/*  API Endpoint to set up the start position of a new game
+	Request JSON example (all fields optional):
+		{
+			"boardSize":8,                  // Any even size from 4 to 16
+			"start":"cross",                // Center pieces, "cross" or "parallel"
+			"position":"...",               // Setup position string instead of boardSize and start
+			"handicap":2,                   // Number of corners given to the weaker side
+			"handicapColour":1,             // Colour of the weaker side (1 black, -1 white)
+			"variant":"standard",           // "standard" or "misere"
+			"blocked":[[0,0]],              // Blocked squares
+			"obstacles":4                   // Number of randomly blocked squares
+		}
+	Response JSON example, accepted as is by /search_move:
+		{
+			"blackFilled":[[0,0],[3,4],[4,3],[7,7]],
+			"whiteFilled":[[3,3],[4,4]],
+			"turn":1,
+			"boardSize":8,
+			"variant":"standard",
+			"position":"X--------------------------OX------XO--------------------------X X"
+		}
+	*/
+	var options = StartOptions{}
+	body, err := ioutil.ReadAll(io.LimitReader(r.Body, 1048576))
+	if err != nil {
+		panic(err)
+	}
+	if len(body) > 0 {
+		if err := json.Unmarshal(body, &options); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+	}
+	game, err := options.Board()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	//Allow CORS here By * or specific origin
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+	json.NewEncoder(w).Encode(game.GameState())
+}
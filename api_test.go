@@ -0,0 +1,70 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func FuzzDecodeGameState(f *testing.F) {
+	// Any body posted to /search_move either fails to decode
+	// or gives a board the agent can search
+	f.Add(`{"blackFilled":[[3,3],[4,4]],"whiteFilled":[[3,4],[4,3]],"turn":1}`)
+	f.Add(`{"position":"---------------------------OX------XO--------------------------- X"}`)
+	f.Add(`{"blackFilled":[[1,1]],"whiteFilled":[[1,2]],"turn":-1,"boardSize":4,"blocked":[[0,0]]}`)
+	f.Add(`{"blackFilled":[[99,0]],"turn":1}`)
+	f.Add(`{"blackFilled":[[-1,0]],"turn":1,"boardSize":16}`)
+	f.Add(`{"turn":5}`)
+	f.Add(`{"variant":"misere","policy":"visits","position":"--------------OX----XO-------------- O"}`)
+	f.Add(`[1,2`)
+	f.Fuzz(func(t *testing.T, body string) {
+		_, game, err := decodeGameState([]byte(body))
+		if err != nil {
+			return
+		}
+		if validBoardSize(game.length) != nil {
+			t.Fatalf("board size %d", game.length)
+		}
+		if game.turn != 1 && game.turn != -1 {
+			t.Fatalf("turn %d", game.turn)
+		}
+		if len(game.validSpace) == 0 {
+			t.Fatalf("no valid move")
+		}
+		for _, move := range game.validSpace {
+			if !game.inRange(move) || game.board[move.i][move.j] != 0 {
+				t.Fatalf("valid move %v is not an empty square of the board", move)
+			}
+		}
+	})
+}
+
+func TestGameStateAPI(t *testing.T) {
+	position := "XXX--X--XX----XXXXOOOOOXXOXOOXXXXOXXOXOXXOXOXOOXOOOOOOXXXXX-OOX- X"
+	game := mustParseBoard(t, position)
+	rec := httptest.NewRecorder()
+	GameStateAPI(rec, httptest.NewRequest("POST", "/search_move", strings.NewReader(`{"position":"`+position+`"}`)))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status %d: %s", rec.Code, rec.Body.String())
+	}
+	response := DecisionResponse{}
+	if err := json.NewDecoder(rec.Body).Decode(&response); err != nil {
+		t.Fatal(err)
+	}
+	if !posInSlice(Position{response.Move[0], response.Move[1]}, game.validSpace) {
+		t.Errorf("move %v is not valid", response.Move)
+	}
+	if response.Colour != game.turn {
+		t.Errorf("colour %d, want %d", response.Colour, game.turn)
+	}
+
+	for _, body := range []string{`{"turn":`, `{"blackFilled":[[8,8]],"turn":1}`, `{"turn":1}`} {
+		rec := httptest.NewRecorder()
+		GameStateAPI(rec, httptest.NewRequest("POST", "/search_move", strings.NewReader(body)))
+		if rec.Code != http.StatusBadRequest {
+			t.Errorf("%s: status %d, want %d", body, rec.Code, http.StatusBadRequest)
+		}
+	}
+}
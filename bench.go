@@ -0,0 +1,176 @@
+// Throughput benchmarks of the engine on fixed positions
+// The bench command measures the rates below and compares them with
+// a baseline file written by an earlier run, to see the effect of changes
+// to the board representation or the search on speed
+// The same positions are used by the Go benchmarks in bench_test.go
+
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"math/rand"
+	"os"
+	"sort"
+	"time"
+)
+
+// Positions of the benchmarks
+var benchPositions = []struct {
+	name     string
+	position string
+}{
+	{"start", "---------------------------OX------XO--------------------------- X"},
+	{"midgame", "-----------XO-------XOOO-XXXOXX---XXX-X-OOOXX-----XXXX--OOO----- X"},
+	{"endgame", "XXX--X--XX----XXXXOOOOOXXOXOOXXXXOXXOXOXXOXOXOOXOOOOOOXXXXX-OOX- X"},
+}
+
+// Seed of the random games the move benchmarks are taken from
+// Rollouts draw their moves from the engine's random source as in a search
+const benchSeed = 1
+
+func benchBoards() []Board {
+	boards := []Board{}
+	for _, bp := range benchPositions {
+		game, err := ParseBoard(bp.position)
+		if err != nil {
+			panic(err)
+		}
+		boards = append(boards, game)
+	}
+	return boards
+}
+
+func benchMoves(n int) ([]Board, []Position) {
+	// n positions of random games from the start with a valid move of each
+	// The games are the same on every call
+	r := rand.New(rand.NewSource(benchSeed))
+	boards := []Board{}
+	moves := []Position{}
+	for len(boards) < n {
+		game := newGame()
+		for game.winner == 0 && len(boards) < n {
+			move := game.validSpace[r.Intn(len(game.validSpace))]
+			boards = append(boards, game)
+			moves = append(moves, move)
+			game.Move(move)
+		}
+	}
+	return boards, moves
+}
+
+type benchmark struct {
+	name string
+	unit string
+	run  func() int // Runs the benchmark once, returns the number of units done
+}
+
+func benchmarkSet() []benchmark {
+	// Rates measured by the bench command, in units per second
+	// Positions are set up here, outside of the measured runs
+	boards, moves := benchMoves(600)
+	return []benchmark{
+		{"move", "moves", func() int {
+			for k, game := range boards {
+				game.Move(moves[k])
+			}
+			return len(boards)
+		}},
+		{"valid", "positions", func() int {
+			for _, game := range boards {
+				game.getAllValid()
+			}
+			return len(boards)
+		}},
+		{"playout", "playouts", func() int {
+			n := 0
+			for _, game := range benchBoards() {
+				Rollout(game, 20, DefaultParams, nil)
+				n += 20
+			}
+			return n
+		}},
+		{"search", "nodes", func() int {
+			n := 0
+			for _, game := range benchBoards() {
+				root := Node{state: game.compact()}
+				searchTree(&root, 5, 100, time.Time{}, nil, DefaultParams)
+				n += countNodes(&root)
+			}
+			return n
+		}},
+	}
+}
+
+func (b benchmark) measure(duration time.Duration) float64 {
+	// Units per second of the benchmark, run for at least duration
+	units := 0
+	start := time.Now()
+	for time.Since(start) < duration {
+		units += b.run()
+	}
+	return float64(units) / time.Since(start).Seconds()
+}
+
+// Baseline file of the bench command, rates by benchmark name
+type benchBaseline map[string]float64
+
+func benchCommand(args []string) error {
+	// Measure the throughput of the engine and compare it with a baseline
+	// Fails if a rate is lower than the baseline by more than -threshold
+	// Example:
+	//     > reversi bench -save -baseline bench.json
+	//     > reversi bench -baseline bench.json -threshold 0.1
+	fs := flag.NewFlagSet("bench", flag.ExitOnError)
+	baseline := fs.String("baseline", "bench.json", "baseline file to compare with or save to")
+	save := fs.Bool("save", false, "save the rates as the new baseline instead of comparing")
+	threshold := fs.Float64("threshold", 0.1, "largest slowdown accepted as a fraction of the baseline rate")
+	duration := fs.Duration("duration", 2*time.Second, "time to run each benchmark for")
+	fs.Parse(args)
+
+	base := benchBaseline{}
+	if !*save {
+		b, err := os.ReadFile(*baseline)
+		if err != nil {
+			return fmt.Errorf("%v, create a baseline with -save", err)
+		}
+		if err := json.Unmarshal(b, &base); err != nil {
+			return fmt.Errorf("%s: %v", *baseline, err)
+		}
+	}
+
+	rates := benchBaseline{}
+	regressed := []string{}
+	for _, b := range benchmarkSet() {
+		rate := b.measure(*duration)
+		rates[b.name] = rate
+		line := fmt.Sprintf("%-8s %12.0f %-12s", b.name, rate, b.unit+"/s")
+		if old, ok := base[b.name]; ok && old > 0 {
+			change := rate/old - 1
+			line += fmt.Sprintf("  baseline %12.0f  %+6.1f%%", old, 100*change)
+			if change < -*threshold {
+				line += "  REGRESSION"
+				regressed = append(regressed, b.name)
+			}
+		}
+		fmt.Println(line)
+	}
+
+	if *save {
+		b, err := json.MarshalIndent(rates, "", "  ")
+		if err != nil {
+			return err
+		}
+		if err := os.WriteFile(*baseline, append(b, '\n'), 0644); err != nil {
+			return err
+		}
+		fmt.Println("Saved baseline to", *baseline)
+		return nil
+	}
+	if len(regressed) > 0 {
+		sort.Strings(regressed)
+		return fmt.Errorf("slower than the baseline by more than %.0f%%: %v", 100**threshold, regressed)
+	}
+	return nil
+}
@@ -0,0 +1,66 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func BenchmarkMove(b *testing.B) {
+	boards, moves := benchMoves(600)
+	b.ResetTimer()
+	for n := 0; n < b.N; n++ {
+		game := boards[n%len(boards)]
+		game.Move(moves[n%len(boards)])
+	}
+}
+
+func BenchmarkGetAllValid(b *testing.B) {
+	boards, _ := benchMoves(600)
+	b.ResetTimer()
+	for n := 0; n < b.N; n++ {
+		game := boards[n%len(boards)]
+		game.getAllValid()
+	}
+}
+
+func BenchmarkNodeStateBoard(b *testing.B) {
+	boards, _ := benchMoves(600)
+	states := make([]nodeState, len(boards))
+	for k, game := range boards {
+		states[k] = game.compact()
+	}
+	b.ResetTimer()
+	for n := 0; n < b.N; n++ {
+		states[n%len(states)].Board()
+	}
+}
+
+func BenchmarkSimRandPlus(b *testing.B) {
+	for k, game := range benchBoards() {
+		b.Run(benchPositions[k].name, func(b *testing.B) {
+			for n := 0; n < b.N; n++ {
+				simRandPlus(game, 2, nil)
+			}
+		})
+	}
+}
+
+func BenchmarkRollout(b *testing.B) {
+	boards := benchBoards()
+	amaf := &amafCounts{}
+	for n := 0; n < b.N; n++ {
+		Rollout(boards[n%len(boards)], 20, DefaultParams, amaf)
+	}
+}
+
+func BenchmarkSearch(b *testing.B) {
+	boards := benchBoards()
+	nodes := 0
+	start := time.Now()
+	for n := 0; n < b.N; n++ {
+		root := Node{state: boards[n%len(boards)].compact()}
+		searchTree(&root, 5, 100, time.Time{}, nil, DefaultParams)
+		nodes += countNodes(&root)
+	}
+	b.ReportMetric(float64(nodes)/time.Since(start).Seconds(), "nodes/s")
+}
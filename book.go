@@ -0,0 +1,200 @@
+// Opening book built from game files
+// Positions are stored in their canonical form, so a position and all its
+// rotations and mirror images share one entry, with the moves played from
+// it mapped along
+// The search plays the move most often played from a position in the book
+// instead of searching
+
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+)
+
+type OpeningBook struct {
+
+	// Struct to hold an opening book
+	// Read from and written to a JSON file
+
+	Plies     int                 `json:"plies"`     // Moves of every game taken into the book
+	Positions map[string][][3]int `json:"positions"` // Moves as [i, j, games] by canonical position string
+}
+
+func buildOpeningBook(games [][]Position, plies int, minGames int) (*OpeningBook, int) {
+	// Book of the first plies moves of games from the standard start
+	// Moves played in fewer than minGames games are left out
+	// Games with an illegal move are only taken in up to that move,
+	// returns the number of such games
+	counts := map[string]map[Position]int{}
+	illegal := 0
+	for _, moves := range games {
+		game := newGame()
+		for ply, move := range moves {
+			if ply >= plies || game.winner != 0 {
+				break
+			}
+			if !posInSlice(move, game.validSpace) {
+				illegal++
+				break
+			}
+			canonical, sym := game.Canonical()
+			key := canonical.PositionString()
+			if counts[key] == nil {
+				counts[key] = map[Position]int{}
+			}
+			counts[key][move.transform(game.length, sym)]++
+			game.Move(move)
+		}
+	}
+	book := &OpeningBook{Plies: plies, Positions: map[string][][3]int{}}
+	for key, moves := range counts {
+		for move, n := range moves {
+			if n >= minGames {
+				book.Positions[key] = append(book.Positions[key], [3]int{move.i, move.j, n})
+			}
+		}
+		sort.Slice(book.Positions[key], func(a, b int) bool {
+			x, y := book.Positions[key][a], book.Positions[key][b]
+			if x[2] != y[2] {
+				return x[2] > y[2]
+			}
+			return x[0] < y[0] || x[0] == y[0] && x[1] < y[1]
+		})
+	}
+	for key, moves := range book.Positions {
+		if len(moves) == 0 {
+			delete(book.Positions, key)
+		}
+	}
+	return book, illegal
+}
+
+func (book *OpeningBook) Moves(game Board) [][3]int {
+	// Moves of the book for game, as [i, j, games] on the board of game,
+	// most played first
+	// Books are built from standard games, misere games are not in them
+	if game.misere {
+		return nil
+	}
+	canonical, sym := game.Canonical()
+	back := inverseSymmetry(sym)
+	moves := [][3]int{}
+	for _, m := range book.Positions[canonical.PositionString()] {
+		move := Position{m[0], m[1]}.transform(game.length, back)
+		if posInSlice(move, game.validSpace) {
+			moves = append(moves, [3]int{move.i, move.j, m[2]})
+		}
+	}
+	return moves
+}
+
+func (book *OpeningBook) Move(game Board) (Position, bool) {
+	// Most played move of the book for game, false if game is not in the book
+	moves := book.Moves(game)
+	if len(moves) == 0 {
+		return Position{}, false
+	}
+	return Position{moves[0][0], moves[0][1]}, true
+}
+
+func LoadOpeningBook(path string) (*OpeningBook, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	book := &OpeningBook{}
+	if err := json.Unmarshal(b, book); err != nil {
+		return nil, fmt.Errorf("%s: %v", path, err)
+	}
+	return book, nil
+}
+
+func (book *OpeningBook) Save(path string) error {
+	b, err := json.Marshal(book)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, b, 0644)
+}
+
+// Opening books already read, by path
+var openingBooks = struct {
+	sync.Mutex
+	loaded map[string]*OpeningBook
+}{loaded: map[string]*OpeningBook{}}
+
+func cachedOpeningBook(path string) (*OpeningBook, error) {
+	// Read the opening book from path, only once for every path
+	openingBooks.Lock()
+	defer openingBooks.Unlock()
+	if book, ok := openingBooks.loaded[path]; ok {
+		return book, nil
+	}
+	book, err := LoadOpeningBook(path)
+	if err != nil {
+		return nil, err
+	}
+	openingBooks.loaded[path] = book
+	return book, nil
+}
+
+func bookCommand(args []string) error {
+	// Build an opening book from game files or show the book moves of a position
+	// Example:
+	//     > reversi book -build -games WTH_2019.wtb,WTH_2020.wtb -plies 16 -book book.json
+	//     > reversi book -book book.json -moves F5D6
+	fs := flag.NewFlagSet("book", flag.ExitOnError)
+	path := fs.String("book", "book.json", "opening book file")
+	build := fs.Bool("build", false, "build the book instead of showing its moves")
+	files := fs.String("games", "", "comma separated game files to build the book from, WTHOR databases (.wtb) or move lists")
+	plies := fs.Int("plies", 16, "moves of every game taken into the book")
+	minGames := fs.Int("min", 2, "games a move has to be played in to be taken into the book")
+	moves := fs.String("moves", "", "moves from the start position to show the book moves of, e.g. F5D6")
+	fs.Parse(args)
+
+	if *build {
+		if *files == "" {
+			return errors.New("no games, give -games files")
+		}
+		games := [][]Position{}
+		for _, file := range strings.Split(*files, ",") {
+			g, err := readGames(file)
+			if err != nil {
+				return err
+			}
+			fmt.Printf("%s: %d games\n", file, len(g))
+			games = append(games, g...)
+		}
+		book, illegal := buildOpeningBook(games, *plies, *minGames)
+		if illegal > 0 {
+			fmt.Printf("%d games with illegal moves taken in up to them\n", illegal)
+		}
+		fmt.Printf("%d positions\n", len(book.Positions))
+		return book.Save(*path)
+	}
+
+	book, err := LoadOpeningBook(*path)
+	if err != nil {
+		return err
+	}
+	game, err := StartOptions{Moves: *moves}.Board()
+	if err != nil {
+		return err
+	}
+	game.Show()
+	bookMoves := book.Moves(game)
+	if len(bookMoves) == 0 {
+		fmt.Println("Not in the book")
+	}
+	for _, m := range bookMoves {
+		fmt.Printf("%s: %d games\n", Position{m[0], m[1]}.Notation(), m[2])
+	}
+	return nil
+}
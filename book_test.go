@@ -0,0 +1,80 @@
+package main
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestInverseSymmetry(t *testing.T) {
+	for _, size := range []int{6, 8} {
+		for sym := 0; sym < symmetries; sym++ {
+			back := inverseSymmetry(sym)
+			for i := 0; i < size; i++ {
+				for j := 0; j < size; j++ {
+					if p := (Position{i, j}).transform(size, sym).transform(size, back); p != (Position{i, j}) {
+						t.Fatalf("size %d symmetry %d: %v mapped back to %v", size, sym, Position{i, j}, p)
+					}
+				}
+			}
+		}
+	}
+}
+
+func TestOpeningBook(t *testing.T) {
+	// A game and its mirror image share the entries of their positions,
+	// and each finds the book move on its own board
+	game := []Position{{4, 5}, {5, 3}, {2, 2}, {2, 3}}
+	mirrored := []Position{}
+	for _, move := range game {
+		mirrored = append(mirrored, move.transform(8, 5))
+	}
+	other := []Position{{4, 5}, {5, 5}, {5, 4}, {3, 5}}
+	book, illegal := buildOpeningBook([][]Position{game, mirrored, other, {{0, 0}}}, 3, 2)
+	if illegal != 1 {
+		t.Errorf("%d games with illegal moves, want 1", illegal)
+	}
+
+	for _, moves := range [][]Position{game, mirrored} {
+		board := newGame()
+		board.playMoves(moves[:2])
+		move, ok := book.Move(board)
+		if !ok || move != moves[2] {
+			t.Errorf("after %s%s: book move %s %v, want %s", moves[0].Notation(), moves[1].Notation(), move.Notation(), ok, moves[2].Notation())
+		}
+
+		// Only the first 3 moves are in the book
+		board.playMoves(moves[2:3])
+		if _, ok := book.Move(board); ok {
+			t.Errorf("book move after the book plies")
+		}
+	}
+
+	// Moves of a single game are left out with a minimum of 2 games
+	board := newGame()
+	board.playMoves(other[:1])
+	if moves := book.Moves(board); len(moves) != 1 || moves[0][2] != 2 {
+		t.Errorf("book moves %v after F5, want only the move of 2 games", moves)
+	}
+	board.misere = true
+	if _, ok := book.Move(board); ok {
+		t.Errorf("book move in the misere variant")
+	}
+
+	// The search plays book moves without searching
+	path := filepath.Join(t.TempDir(), "book.json")
+	if err := book.Save(path); err != nil {
+		t.Fatal(err)
+	}
+	p := DefaultParams
+	if err := p.SetString("book", path); err != nil {
+		t.Fatal(err)
+	}
+	board = newGame()
+	board.playMoves(game[:2])
+	root := Node{state: board.compact()}
+	move, policy := searchTree(&root, 1, 100, time.Time{}, nil, p)
+	if move != game[2] || policy != "book" || root.played != 0 {
+		t.Errorf("search returned %s by %s after %d playouts, want %s from the book", move.Notation(), policy, root.played, game[2].Notation())
+	}
+}
@@ -0,0 +1,98 @@
+// Game clocks for timed games
+// Each side has a total time and gains an increment after every move
+// The time manager splits the remaining time of the agent over
+// the moves it still has to make
+
+package main
+
+import (
+	"time"
+)
+
+const (
+	moveOverhead = 50 * time.Millisecond // Reserve per move for the server and network
+	minMoveTime  = 10 * time.Millisecond // Shortest search even when almost out of time
+)
+
+type Clock struct {
+
+	// Struct to hold the clocks of both players
+	// Only the clock of the side to move runs
+
+	Remaining [2]time.Duration // Time left for black (index 0) and white (index 1)
+	Increment time.Duration    // Time added after each move
+	running   int              // Colour whose clock is running, 0 if stopped
+	started   time.Time        // When the running clock was started
+}
+
+func colourIndex(colour int) int {
+	if colour == -1 {
+		return 1
+	}
+	return 0
+}
+
+func NewClock(total time.Duration, increment time.Duration) *Clock {
+	return &Clock{
+		Remaining: [2]time.Duration{total, total},
+		Increment: increment,
+	}
+}
+
+func (c *Clock) Start(colour int) {
+	// Start the clock of colour, stopping the running clock first
+	c.Stop()
+	c.running = colour
+	c.started = time.Now()
+}
+
+func (c *Clock) Stop() {
+	// Stop the running clock and charge the elapsed time
+	if c.running == 0 {
+		return
+	}
+	c.Remaining[colourIndex(c.running)] -= time.Since(c.started)
+	c.running = 0
+}
+
+func (c *Clock) Press(next int) {
+	// Called when the running side has made its move
+	// Adds the increment to the running side and starts the clock of next
+	if c.running != 0 {
+		colour := c.running
+		c.Stop()
+		c.Remaining[colourIndex(colour)] += c.Increment
+	}
+	c.Start(next)
+}
+
+func (c *Clock) Left(colour int) time.Duration {
+	// Time left for colour, including the time used on a running clock
+	left := c.Remaining[colourIndex(colour)]
+	if c.running == colour {
+		left -= time.Since(c.started)
+	}
+	return left
+}
+
+func (c *Clock) Flagged(colour int) bool {
+	// Whether colour has run out of time
+	return c.Left(colour) <= 0
+}
+
+func allocateTime(remaining time.Duration, increment time.Duration, empties int) time.Duration {
+	// Time budget for the next move of the side to move
+	// Each side makes about half of the moves left on the board,
+	// so the remaining time is spread over empties / 2 moves
+	// and most of the increment is spent on the move it is gained for
+	// Never uses more than half the remaining time on a single move
+	movesLeft := empties/2 + 1
+	budget := remaining/time.Duration(movesLeft) + increment*3/4 - moveOverhead
+	if limit := remaining/2 - moveOverhead; budget > limit {
+		budget = limit
+	}
+	if budget < minMoveTime {
+		budget = minMoveTime
+	}
+	return budget
+}
@@ -0,0 +1,65 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestAllocateTime(t *testing.T) {
+	for _, c := range []struct {
+		remaining time.Duration
+		increment time.Duration
+		empties   int
+		want      time.Duration
+	}{
+		{60 * time.Second, 0, 60, 60*time.Second/31 - moveOverhead},
+		{60 * time.Second, 2 * time.Second, 60, 60*time.Second/31 + 1500*time.Millisecond - moveOverhead},
+		{60 * time.Second, 0, 0, 30*time.Second - moveOverhead},                 // Last move, half the remaining time
+		{time.Second, 10 * time.Second, 2, 500*time.Millisecond - moveOverhead}, // The increment does not lift the half limit
+		{30 * time.Millisecond, 0, 20, minMoveTime},                             // Almost out of time
+		{-time.Second, 0, 20, minMoveTime},
+	} {
+		if got := allocateTime(c.remaining, c.increment, c.empties); got != c.want {
+			t.Errorf("allocateTime(%v, %v, %d) = %v, want %v", c.remaining, c.increment, c.empties, got, c.want)
+		}
+	}
+
+	// Never more than half the remaining time, and more time as the board fills up
+	for remaining := 100 * time.Millisecond; remaining <= 10*time.Minute; remaining *= 3 {
+		last := time.Duration(0)
+		for empties := 60; empties >= 0; empties-- {
+			budget := allocateTime(remaining, time.Second, empties)
+			if budget > remaining/2 {
+				t.Errorf("allocateTime(%v, 1s, %d) = %v, more than half", remaining, empties, budget)
+			}
+			if budget < last {
+				t.Errorf("allocateTime(%v, 1s, %d) = %v, less than %v with more empty squares", remaining, empties, budget, last)
+			}
+			last = budget
+		}
+	}
+}
+
+func TestClockPress(t *testing.T) {
+	// The running side is charged for its time and gains the increment
+	// Clocks are moved back in time instead of waiting
+	c := NewClock(time.Minute, 2*time.Second)
+	c.Start(1)
+	c.started = c.started.Add(-10 * time.Second)
+	c.Press(-1)
+	if left := c.Left(1); left > 52*time.Second || left < 51*time.Second {
+		t.Errorf("black has %v left, want about 52s", left)
+	}
+	if c.Left(-1) > time.Minute || c.running != -1 {
+		t.Errorf("white's clock not running after black's move")
+	}
+
+	c.started = c.started.Add(-2 * time.Minute)
+	if !c.Flagged(-1) || c.Flagged(1) {
+		t.Errorf("flags black %v white %v, want only white", c.Flagged(1), c.Flagged(-1))
+	}
+	c.Stop()
+	if c.running != 0 || c.Remaining[1] > -time.Minute {
+		t.Errorf("stopped clock running %d with white at %v", c.running, c.Remaining[1])
+	}
+}
@@ -0,0 +1,338 @@
+// Elo ratings and sequential probability ratio test (SPRT)
+// for comparing agents from tournament results
+
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"math"
+	"os"
+	"sort"
+)
+
+// z-score for the 95% confidence intervals reported on ratings
+const eloConfidenceZ = 1.96
+
+func scoreFromElo(elo float64) float64 {
+	// Expected score of a player rated elo points above the opponent
+	return 1 / (1 + math.Pow(10, -elo/400))
+}
+
+func eloFromScore(score float64) float64 {
+	// Elo difference corresponding to an expected score
+	// Scores of 0 and 1 give -Inf and +Inf
+	return -400 * math.Log10(1/score-1)
+}
+
+func EloDiff(wins int, draws int, losses int) (float64, float64, float64) {
+	// Elo difference of a player against an opponent from its results
+	// Returns the estimate and the bounds of the 95% confidence interval
+	n := float64(wins + draws + losses)
+	if n == 0 {
+		return 0, math.Inf(-1), math.Inf(1)
+	}
+	w := float64(wins) / n
+	d := float64(draws) / n
+	l := float64(losses) / n
+	score := w + d/2
+
+	// Standard error of the mean score per game
+	variance := w*math.Pow(1-score, 2) + d*math.Pow(0.5-score, 2) + l*math.Pow(score, 2)
+	stderr := math.Sqrt(variance / n)
+	lower := math.Max(score-eloConfidenceZ*stderr, 0)
+	upper := math.Min(score+eloConfidenceZ*stderr, 1)
+	return eloFromScore(score), eloFromScore(lower), eloFromScore(upper)
+}
+
+type Rating struct {
+
+	// Struct to hold the rating of an agent computed over tournament games
+
+	Agent string  `json:"agent"`
+	Games int     `json:"games"`
+	Elo   float64 `json:"elo"`   // Rating relative to the average agent (0)
+	Lower float64 `json:"lower"` // Lower bound of the 95% confidence interval
+	Upper float64 `json:"upper"` // Upper bound of the 95% confidence interval
+}
+
+func Ratings(games []TournamentGame) []Rating {
+	// Maximum likelihood Elo ratings (Bradley-Terry model) for all agents
+	// Draws count as half a win for each side
+	// Like BayesElo, every pairing gets a prior of one virtual draw
+	// so that agents winning or losing every game have a finite rating
+	// Returns ratings sorted from best to worst, with an average of 0
+	index := map[string]int{}
+	names := []string{}
+	for _, g := range games {
+		for _, name := range []string{g.Black, g.White} {
+			if _, ok := index[name]; !ok {
+				index[name] = len(names)
+				names = append(names, name)
+			}
+		}
+	}
+	n := len(names)
+	played := make([][]float64, n) // Games between each pair of agents
+	for i := range played {
+		played[i] = make([]float64, n)
+	}
+	points := make([]float64, n) // Points scored by each agent
+	nGames := make([]int, n)
+	for _, g := range games {
+		b, w := index[g.Black], index[g.White]
+		played[b][w]++
+		played[w][b]++
+		nGames[b]++
+		nGames[w]++
+		switch g.Winner {
+		case 1:
+			points[b]++
+		case -1:
+			points[w]++
+		default:
+			points[b] += 0.5
+			points[w] += 0.5
+		}
+	}
+	for i := 0; i < n; i++ {
+		for j := 0; j < n; j++ {
+			if i != j && played[i][j] > 0 {
+				played[i][j]++
+				points[i] += 0.5
+			}
+		}
+	}
+
+	// Minorization-maximization iterations on gamma = 10^(elo/400)
+	gamma := make([]float64, n)
+	for i := range gamma {
+		gamma[i] = 1
+	}
+	for iter := 0; iter < 10000; iter++ {
+		change := 0.0
+		for i := 0; i < n; i++ {
+			denom := 0.0
+			for j := 0; j < n; j++ {
+				if played[i][j] > 0 {
+					denom += played[i][j] / (gamma[i] + gamma[j])
+				}
+			}
+			if denom == 0 {
+				continue
+			}
+			next := points[i] / denom
+			change = math.Max(change, math.Abs(math.Log(next/gamma[i])))
+			gamma[i] = next
+		}
+		if change < 1e-10 {
+			break
+		}
+	}
+
+	// Convert to Elo anchored at an average of 0
+	// Confidence intervals from the Fisher information of each rating
+	ratings := make([]Rating, n)
+	mean := 0.0
+	for i := 0; i < n; i++ {
+		ratings[i].Elo = 400 * math.Log10(gamma[i])
+		mean += ratings[i].Elo / float64(n)
+	}
+	for i := 0; i < n; i++ {
+		info := 0.0
+		for j := 0; j < n; j++ {
+			if played[i][j] > 0 {
+				p := gamma[i] / (gamma[i] + gamma[j])
+				info += played[i][j] * p * (1 - p)
+			}
+		}
+		margin := math.Inf(1)
+		if info > 0 {
+			margin = eloConfidenceZ * 400 / math.Ln10 / math.Sqrt(info)
+		}
+		ratings[i].Agent = names[i]
+		ratings[i].Games = nGames[i]
+		ratings[i].Elo -= mean
+		ratings[i].Lower = ratings[i].Elo - margin
+		ratings[i].Upper = ratings[i].Elo + margin
+	}
+	sort.SliceStable(ratings, func(a, b int) bool {
+		return ratings[a].Elo > ratings[b].Elo
+	})
+	return ratings
+}
+
+func printRatings(ratings []Rating) {
+	fmt.Printf("%-20s %6s %8s %18s\n", "Agent", "Games", "Elo", "95% CI")
+	for _, r := range ratings {
+		fmt.Printf("%-20s %6d %8.1f [%7.1f, %7.1f]\n", r.Agent, r.Games, r.Elo, r.Lower, r.Upper)
+	}
+}
+
+type SPRT struct {
+	Elo0  float64 // Elo difference of the null hypothesis (H0)
+	Elo1  float64 // Elo difference of the alternative hypothesis (H1)
+	Alpha float64 // Probability of accepting H1 when H0 is true
+	Beta  float64 // Probability of accepting H0 when H1 is true
+}
+
+func (s SPRT) Bounds() (float64, float64) {
+	// Log-likelihood ratio bounds to accept H0 (lower) or H1 (upper)
+	return math.Log(s.Beta / (1 - s.Alpha)), math.Log((1 - s.Beta) / s.Alpha)
+}
+
+func (s SPRT) LLR(wins int, draws int, losses int) float64 {
+	// Log-likelihood ratio of H1 against H0 given the results so far
+	// Uses the normal approximation of the mean score per game
+	// Half a win and half a loss are added to the results, so the variance
+	// is not 0 when one agent wins, loses or draws every game
+	if wins+draws+losses == 0 {
+		return 0
+	}
+	n := float64(wins+draws+losses) + 1
+	w := (float64(wins) + 0.5) / n
+	d := float64(draws) / n
+	l := (float64(losses) + 0.5) / n
+	score := w + d/2
+	variance := w*math.Pow(1-score, 2) + d*math.Pow(0.5-score, 2) + l*math.Pow(score, 2)
+	s0 := scoreFromElo(s.Elo0)
+	s1 := scoreFromElo(s.Elo1)
+	return n * (math.Pow(score-s0, 2) - math.Pow(score-s1, 2)) / (2 * variance)
+}
+
+func (s SPRT) Status(wins int, draws int, losses int) int {
+	// Result of the test so far
+	// H1 accepted (1), H0 accepted (-1), Undetermined (0)
+	llr := s.LLR(wins, draws, losses)
+	lower, upper := s.Bounds()
+	if llr >= upper {
+		return 1
+	}
+	if llr <= lower {
+		return -1
+	}
+	return 0
+}
+
+func ratingCommand(args []string) error {
+	// Compute Elo ratings from the JSON results of tournaments
+	// Example:
+	//     > reversi rating results.json more-results.json
+	fs := flag.NewFlagSet("rating", flag.ExitOnError)
+	fs.Parse(args)
+	if fs.NArg() == 0 {
+		return errors.New("no tournament result files given")
+	}
+	games := []TournamentGame{}
+	for _, path := range fs.Args() {
+		b, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		report := tournamentReport{}
+		if err := json.Unmarshal(b, &report); err != nil {
+			return fmt.Errorf("%s: %v", path, err)
+		}
+		games = append(games, report.Games...)
+	}
+	printRatings(Ratings(games))
+	return nil
+}
+
+func sprtCommand(args []string) error {
+	// Play games between two agents until the SPRT accepts either hypothesis
+	// H0: the first agent is at most elo0 stronger than the second
+	// H1: the first agent is at least elo1 stronger than the second
+	// Example:
+	//     > reversi sprt -elo0 0 -elo1 20 new=mcts:20:300 old=mcts:20:200
+	fs := flag.NewFlagSet("sprt", flag.ExitOnError)
+	test := SPRT{}
+	fs.Float64Var(&test.Elo0, "elo0", 0, "Elo difference of the null hypothesis")
+	fs.Float64Var(&test.Elo1, "elo1", 20, "Elo difference of the alternative hypothesis")
+	fs.Float64Var(&test.Alpha, "alpha", 0.05, "false positive rate")
+	fs.Float64Var(&test.Beta, "beta", 0.05, "false negative rate")
+	maxGames := fs.Int("max-games", 20000, "stop undecided after this many games")
+	openingsPath := fs.String("openings", "", "opening suite file, one move sequence per line")
+	concurrency := fs.Int("concurrency", 1, "number of games played at the same time")
+	fs.Parse(args)
+	if fs.NArg() != 2 {
+		return errors.New("sprt needs exactly two agents")
+	}
+	if test.Elo1 <= test.Elo0 {
+		return errors.New("elo1 must be greater than elo0")
+	}
+	agents := []Agent{}
+	for _, spec := range fs.Args() {
+		agent, err := ParseAgent(spec)
+		if err != nil {
+			return err
+		}
+		agents = append(agents, agent)
+	}
+	if agents[0].Name() == agents[1].Name() {
+		return errors.New("both agents have the same name, name agents with name=spec")
+	}
+	openings := [][]Position{}
+	if *openingsPath != "" {
+		var err error
+		if openings, err = readOpenings(*openingsPath); err != nil {
+			return err
+		}
+	}
+
+	// Play game pairs (same opening, both colours) in batches
+	// and check the test after each batch
+	lower, upper := test.Bounds()
+	wins, draws, losses := 0, 0, 0
+	batch := *concurrency
+	if batch < 1 {
+		batch = 1
+	}
+	next := 0
+	status := 0
+	for status == 0 && wins+draws+losses < *maxGames {
+		t := Tournament{
+			Agents:      agents,
+			Rounds:      batch,
+			Concurrency: *concurrency,
+		}
+		if len(openings) > 0 {
+			t.Rounds = 1
+			for k := 0; k < batch; k++ {
+				t.Openings = append(t.Openings, openings[next%len(openings)])
+				next++
+			}
+		}
+		games, err := t.Run(nil)
+		if err != nil {
+			return err
+		}
+		for _, g := range games {
+			switch {
+			case g.Winner == 99:
+				draws++
+			case (g.Winner == 1) == (g.Black == agents[0].Name()):
+				wins++
+			default:
+				losses++
+			}
+		}
+		status = test.Status(wins, draws, losses)
+		elo, eloLower, eloUpper := EloDiff(wins, draws, losses)
+		fmt.Printf("Games: %d, W: %d, D: %d, L: %d, Elo: %.1f [%.1f, %.1f], LLR: %.3f [%.3f, %.3f]\n",
+			wins+draws+losses, wins, draws, losses, elo, eloLower, eloUpper,
+			test.LLR(wins, draws, losses), lower, upper,
+		)
+	}
+	switch status {
+	case 1:
+		fmt.Printf("H1 accepted: %s is at least %.1f Elo stronger than %s\n", agents[0].Name(), test.Elo1, agents[1].Name())
+	case -1:
+		fmt.Printf("H0 accepted: %s is not more than %.1f Elo stronger than %s\n", agents[0].Name(), test.Elo0, agents[1].Name())
+	default:
+		fmt.Println("Undecided after", wins+draws+losses, "games")
+	}
+	return nil
+}
@@ -0,0 +1,57 @@
+package main
+
+import (
+	"testing"
+)
+
+func sprtGames(test SPRT, result func(game int) (int, int, int), maxGames int) (int, int) {
+	// Number of games until the test decides, and its status
+	wins, draws, losses := 0, 0, 0
+	for game := 0; game < maxGames; game++ {
+		w, d, l := result(game)
+		wins, draws, losses = wins+w, draws+d, losses+l
+		if status := test.Status(wins, draws, losses); status != 0 {
+			return game + 1, status
+		}
+	}
+	return maxGames, 0
+}
+
+func TestSPRTOneSided(t *testing.T) {
+	// Agents winning, losing or drawing every game are decided early
+	test := SPRT{Elo0: 0, Elo1: 20, Alpha: 0.05, Beta: 0.05}
+	cases := []struct {
+		name   string
+		result func(game int) (int, int, int)
+		status int
+	}{
+		{"wins", func(int) (int, int, int) { return 1, 0, 0 }, 1},
+		{"losses", func(int) (int, int, int) { return 0, 0, 1 }, -1},
+		{"draws", func(int) (int, int, int) { return 0, 1, 0 }, -1},
+	}
+	for _, tc := range cases {
+		games, status := sprtGames(test, tc.result, 1000)
+		if status != tc.status {
+			t.Errorf("%s: status %d after %d games, want %d", tc.name, status, games, tc.status)
+		} else if games > 50 {
+			t.Errorf("%s: decided after %d games", tc.name, games)
+		}
+	}
+	if status := test.Status(1, 0, 0); status != 0 {
+		t.Errorf("decided after a single win")
+	}
+}
+
+func TestSPRTEven(t *testing.T) {
+	// Alternating wins and losses between equal agents accept H0
+	test := SPRT{Elo0: 0, Elo1: 20, Alpha: 0.05, Beta: 0.05}
+	games, status := sprtGames(test, func(game int) (int, int, int) {
+		if game%2 == 0 {
+			return 1, 0, 0
+		}
+		return 0, 0, 1
+	}, 100000)
+	if status != -1 {
+		t.Errorf("status %d after %d games, want H0", status, games)
+	}
+}
@@ -0,0 +1,443 @@
+// Server-side games against the agent
+// The server keeps the board and the clocks of each game,
+// so timed matches can be played against the agent
+// with the agent's search time managed by allocateTime
+// Games are removed once they are finished or idle for a while,
+// and the number of games on the server is limited
+
+package main
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"math"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+type GameSession struct {
+
+	// Struct to hold a game played on the server
+	// The mutex guards all fields as moves may arrive concurrently
+
+	mu     sync.Mutex
+	id     string
+	game   Board
+	agent  int        // Colour the agent plays as (1 black, -1 white)
+	clock  *Clock     // Clocks of both players, nil for untimed games
+	nSims  int        // Rollouts per leaf of the agent
+	moves  []Position // Moves played so far
+	winner int        // Winner of the game as in Board, set when the game ends
+	reason string     // How the game ended: "board" or "time"
+
+	ponder    bool      // Whether the agent searches while the human is thinking
+	pondering *ponderer // Background search of the current position, nil if not running
+	reused    int       // Playouts carried over from pondering into the agent's last search
+	params    Params    // Search parameters of the agent
+	policy    string    // Policy that selected the agent's last move
+	active    time.Time // Time of the last request to the game
+}
+
+const (
+	maxSessions         = 1000             // Games kept on the server at the same time
+	sessionIdleTime     = time.Hour        // Games without requests for this long are removed
+	sessionFinishedTime = 10 * time.Minute // Finished games are kept this long for the result
+)
+
+type NewSessionRequest struct {
+	StartOptions
+	AgentColour int     `json:"agentColour"` // Colour of the agent (1 black, -1 white), white if not given
+	Time        float64 `json:"time"`        // Seconds on each clock, 0 for an untimed game
+	Increment   float64 `json:"increment"`   // Seconds added after each move
+	Sims        int     `json:"sims"`        // Rollouts per leaf of the agent, 20 if not given
+	Ponder      bool    `json:"ponder"`      // Search in the background while the human is thinking
+	Policy      string  `json:"policy"`      // Final move policy of the agent, heuristic if not given
+}
+
+type SessionMove struct {
+	Move     [2]int `json:"move"`     // Move as [i, j]
+	Notation string `json:"notation"` // Alternative to move in notation, e.g. "D3"
+}
+
+type SessionView struct {
+
+	// Struct to hold the response for a server-side game
+	// The embedded GameState holds the current position
+
+	ID string `json:"id"`
+	GameState
+	AgentColour int      `json:"agentColour"`
+	Moves       []string `json:"moves"`      // Moves played so far in notation
+	ValidMoves  [][2]int `json:"validMoves"` // Valid moves for the side to move
+	BlackScore  int      `json:"blackScore"`
+	WhiteScore  int      `json:"whiteScore"`
+	BlackTime   float64  `json:"blackTime,omitempty"` // Seconds left on black's clock
+	WhiteTime   float64  `json:"whiteTime,omitempty"` // Seconds left on white's clock
+	Winner      int      `json:"winner"`              // Black (1), White (-1), Draw (99), still playing (0)
+	Reason      string   `json:"reason,omitempty"`    // "board" or "time" once the game has ended
+	Reused      int      `json:"reused,omitempty"`    // Playouts of the agent's last search done while pondering
+	Policy      string   `json:"policy,omitempty"`    // Policy that selected the agent's last move
+}
+
+var sessions = struct {
+	sync.Mutex
+	games map[string]*GameSession
+}{games: map[string]*GameSession{}}
+
+func newSessionID() string {
+	b := make([]byte, 8)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+func getSession(id string) (*GameSession, bool) {
+	sessions.Lock()
+	defer sessions.Unlock()
+	s, ok := sessions.games[id]
+	return s, ok
+}
+
+func (s *GameSession) expired(now time.Time) bool {
+	idle := now.Sub(s.active)
+	return idle > sessionIdleTime || s.finished() && idle > sessionFinishedTime
+}
+
+func expireSessions(now time.Time) int {
+	// Remove finished and idle games and stop their background searches
+	// Returns the number of games left
+	// Sessions are locked one at a time without holding the sessions lock
+	// A session busy with a request, e.g. the agent's search, is in use
+	// and is skipped rather than waited for
+	sessions.Lock()
+	list := make([]*GameSession, 0, len(sessions.games))
+	for _, s := range sessions.games {
+		list = append(list, s)
+	}
+	sessions.Unlock()
+	for _, s := range list {
+		if !s.mu.TryLock() {
+			continue
+		}
+		s.checkFlag()
+		expired := s.expired(now)
+		if expired {
+			s.ponder = false // No new search from a request still holding the game
+			s.stopPonder()
+		}
+		s.mu.Unlock()
+		if expired {
+			sessions.Lock()
+			delete(sessions.games, s.id)
+			sessions.Unlock()
+		}
+	}
+	sessions.Lock()
+	defer sessions.Unlock()
+	return len(sessions.games)
+}
+
+func expireSessionsEvery(interval time.Duration) {
+	// Remove finished and idle games periodically, run by the server
+	for now := range time.Tick(interval) {
+		expireSessions(now)
+	}
+}
+
+func (s *GameSession) finished() bool {
+	return s.winner != 0
+}
+
+func (s *GameSession) checkFlag() bool {
+	// End the game on time if the side to move has run out of time
+	if s.clock == nil || !s.clock.Flagged(s.game.turn) {
+		return false
+	}
+	s.clock.Stop()
+	s.stopPonder()
+	s.winner = -s.game.turn
+	s.reason = "time"
+	return true
+}
+
+func (s *GameSession) startPonder() {
+	// Search the position in the background while the human is thinking
+	// The search is limited by the human's clock so it cannot run forever
+	if !s.ponder || s.finished() || s.game.turn == s.agent {
+		return
+	}
+	limit := maxPonderTime
+	if s.clock != nil && s.clock.Left(s.game.turn) < limit {
+		limit = s.clock.Left(s.game.turn)
+	}
+	s.pondering = startPonder(s.game, s.nSims, limit, s.params) // nil if too many searches are running
+}
+
+func (s *GameSession) stopPonder() *ponderer {
+	// Stop the background search, returns the stopped search if there was one
+	pd := s.pondering
+	if pd != nil {
+		pd.Stop()
+		s.pondering = nil
+	}
+	return pd
+}
+
+func (s *GameSession) play(move Position) {
+	// Play a move for the side to move and hand the clock over
+	s.game.Move(move)
+	s.moves = append(s.moves, move)
+	if s.game.winner != 0 {
+		s.winner = s.game.winner
+		s.reason = "board"
+		if s.clock != nil {
+			s.clock.Stop()
+		}
+		return
+	}
+	if s.clock != nil {
+		s.clock.Press(s.game.turn)
+	}
+}
+
+func (s *GameSession) agentMoves(root *Node) {
+	// Let the agent move for as long as it is its turn
+	// The agent keeps moving while the human has to pass
+	// root is the tree kept from pondering for the first move, or nil
+	// Pondering starts again once it is the human's turn
+	s.reused = 0
+	if root != nil {
+		s.reused = root.played
+	}
+	for !s.finished() && s.game.turn == s.agent {
+		if root == nil {
+			root = &Node{
+				state: s.game.compact(),
+				depth: 0,
+			}
+		}
+		maxIter, deadline := 300, time.Time{}
+		if s.clock != nil {
+			budget := allocateTime(s.clock.Left(s.agent), s.clock.Increment, s.game.emptyCount())
+			maxIter, deadline = math.MaxInt, time.Now().Add(budget)
+		}
+		decision, policy := searchTree(root, s.nSims, maxIter, deadline, nil, s.params)
+		s.policy = policy
+		root = nil
+		if s.checkFlag() {
+			return
+		}
+		s.play(decision)
+	}
+	s.startPonder()
+}
+
+func (s *GameSession) View() SessionView {
+	view := SessionView{
+		ID:          s.id,
+		GameState:   s.game.GameState(),
+		AgentColour: s.agent,
+		Moves:       []string{},
+		ValidMoves:  [][2]int{},
+		BlackScore:  s.game.blackScore,
+		WhiteScore:  s.game.whiteScore,
+		Winner:      s.winner,
+		Reason:      s.reason,
+		Reused:      s.reused,
+		Policy:      s.policy,
+	}
+	for _, m := range s.moves {
+		view.Moves = append(view.Moves, m.Notation())
+	}
+	if !s.finished() {
+		for _, m := range s.game.validSpace {
+			view.ValidMoves = append(view.ValidMoves, [2]int{m.i, m.j})
+		}
+	}
+	if s.clock != nil {
+		view.BlackTime = s.clock.Left(1).Seconds()
+		view.WhiteTime = s.clock.Left(-1).Seconds()
+	}
+	return view
+}
+
+func writeSession(w http.ResponseWriter, s *GameSession) {
+	//Allow CORS here By * or specific origin
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+	json.NewEncoder(w).Encode(s.View())
+}
+
+func NewSessionAPI(w http.ResponseWriter, r *http.Request) {
+	/*  API Endpoint to start a game on the server
+	Request JSON example, any field of /new_game can be given as well:
+		{
+			"agentColour":-1,               // Agent plays white
+			"time":300,                     // 5 minutes on each clock
+			"increment":2,                  // 2 seconds added after each move
+			"sims":20,                      // Rollouts per leaf of the agent
+			"ponder":true                   // Search while the human is thinking
+		}
+	Response JSON example:
+		{
+			"id":"3f2a9c1e5b7d4a60",
+			"blackFilled":[[3,4],[4,3]],
+			"whiteFilled":[[3,3],[4,4]],
+			"turn":1,
+			...
+			"moves":[],
+			"validMoves":[[2,3],[3,2],[4,5],[5,4]],
+			"blackTime":300,
+			"whiteTime":300,
+			"winner":0
+		}
+	*/
+	req := NewSessionRequest{}
+	body, err := ioutil.ReadAll(io.LimitReader(r.Body, 1048576))
+	if err != nil {
+		panic(err)
+	}
+	if len(body) > 0 {
+		if err := json.Unmarshal(body, &req); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+	}
+	game, err := req.StartOptions.Board()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if req.AgentColour == 0 {
+		req.AgentColour = -1
+	}
+	if req.AgentColour != 1 && req.AgentColour != -1 {
+		http.Error(w, "agentColour must be 1 (black) or -1 (white)", http.StatusBadRequest)
+		return
+	}
+	if req.Time < 0 || req.Increment < 0 {
+		http.Error(w, "time and increment must not be negative", http.StatusBadRequest)
+		return
+	}
+	if req.Sims <= 0 {
+		req.Sims = 20
+	}
+	if err := validFinalPolicy(req.Policy); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if expireSessions(time.Now()) >= maxSessions {
+		http.Error(w, "too many games on the server, try again later", http.StatusServiceUnavailable)
+		return
+	}
+	s := &GameSession{
+		id:     newSessionID(),
+		game:   game,
+		agent:  req.AgentColour,
+		nSims:  req.Sims,
+		ponder: req.Ponder,
+		params: DefaultParams,
+		active: time.Now(),
+	}
+	s.params.FinalPolicy = req.Policy
+	if req.Time > 0 {
+		s.clock = NewClock(
+			time.Duration(req.Time*float64(time.Second)),
+			time.Duration(req.Increment*float64(time.Second)),
+		)
+		s.clock.Start(game.turn)
+	}
+	sessions.Lock()
+	sessions.games[s.id] = s
+	sessions.Unlock()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.agentMoves(nil)
+	writeSession(w, s)
+}
+
+func SessionAPI(w http.ResponseWriter, r *http.Request) {
+	// API Endpoint to get the state of a game on the server
+	s, ok := getSession(mux.Vars(r)["id"])
+	if !ok {
+		http.Error(w, "game not found", http.StatusNotFound)
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.active = time.Now()
+	s.checkFlag()
+	writeSession(w, s)
+}
+
+func SessionMoveAPI(w http.ResponseWriter, r *http.Request) {
+	/*  API Endpoint to play a move in a game on the server
+	The agent replies in the same request, the response holds the game
+	after the agent's move
+	Request JSON example:
+		{
+			"move":[2,3]
+		}
+	or in notation:
+		{
+			"notation":"D3"
+		}
+	*/
+	s, ok := getSession(mux.Vars(r)["id"])
+	if !ok {
+		http.Error(w, "game not found", http.StatusNotFound)
+		return
+	}
+	req := SessionMove{}
+	body, err := ioutil.ReadAll(io.LimitReader(r.Body, 1048576))
+	if err != nil {
+		panic(err)
+	}
+	if err := json.Unmarshal(body, &req); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	move := Position{req.Move[0], req.Move[1]}
+	if req.Notation != "" {
+		if move, err = parseNotation(req.Notation); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.active = time.Now()
+	if s.checkFlag() || s.finished() {
+		http.Error(w, "game is over", http.StatusConflict)
+		return
+	}
+	if s.game.turn == s.agent {
+		http.Error(w, "it is the agent's turn", http.StatusConflict)
+		return
+	}
+	if !s.game.inRange(move) {
+		http.Error(w, fmt.Sprintf("move %v is outside the %dx%d board", [2]int{move.i, move.j}, s.game.length, s.game.length), http.StatusBadRequest)
+		return
+	}
+	if !posInSlice(move, s.game.validSpace) {
+		http.Error(w, fmt.Sprintf("%s is not a valid move", move.Notation()), http.StatusBadRequest)
+		return
+	}
+	var root *Node
+	if pd := s.stopPonder(); pd != nil {
+		root = pd.subtree(move)
+	}
+	s.play(move)
+	if root != nil && root.state.turn != s.agent {
+		root = nil // The agent has to pass, the kept tree is for the wrong side
+	}
+	s.agentMoves(root)
+	writeSession(w, s)
+}
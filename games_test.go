@@ -0,0 +1,134 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+func newTestSession(t *testing.T, body string) *GameSession {
+	rec := httptest.NewRecorder()
+	NewSessionAPI(rec, httptest.NewRequest("POST", "/games", strings.NewReader(body)))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status %d: %s", rec.Code, rec.Body.String())
+	}
+	view := SessionView{}
+	if err := json.NewDecoder(rec.Body).Decode(&view); err != nil {
+		t.Fatal(err)
+	}
+	s, ok := getSession(view.ID)
+	if !ok {
+		t.Fatalf("game %s not kept", view.ID)
+	}
+	return s
+}
+
+func TestSessionExpiry(t *testing.T) {
+	// Idle and finished games are removed and their searches stopped
+	idle := newTestSession(t, `{"ponder":true,"agentColour":-1,"sims":1}`)
+	finished := newTestSession(t, `{"sims":1}`)
+	playing := newTestSession(t, `{"sims":1}`)
+	idle.mu.Lock()
+	pd := idle.pondering
+	idle.mu.Unlock()
+	if pd == nil {
+		t.Fatalf("not pondering")
+	}
+	finished.mu.Lock()
+	finished.winner = 1
+	finished.mu.Unlock()
+
+	now := time.Now()
+	expireSessions(now.Add(sessionFinishedTime + time.Second))
+	if _, ok := getSession(finished.id); ok {
+		t.Errorf("finished game kept")
+	}
+	if _, ok := getSession(idle.id); !ok {
+		t.Errorf("game removed before it was idle for %v", sessionIdleTime)
+	}
+	expireSessions(now.Add(sessionIdleTime + time.Second))
+	for _, s := range []*GameSession{idle, playing} {
+		if _, ok := getSession(s.id); ok {
+			t.Errorf("idle game kept")
+		}
+	}
+	select {
+	case <-pd.done:
+	default:
+		t.Errorf("search of a removed game still running")
+	}
+}
+
+func TestSessionExpiryBusy(t *testing.T) {
+	// A game busy with a search is skipped, not waited for
+	busy := newTestSession(t, `{"sims":1}`)
+	idle := newTestSession(t, `{"sims":1}`)
+	busy.mu.Lock()
+	done := make(chan struct{})
+	go func() {
+		expireSessions(time.Now().Add(sessionIdleTime + time.Second))
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		busy.mu.Unlock()
+		t.Fatalf("expiry waited for a busy game")
+	}
+	busy.mu.Unlock()
+	if _, ok := getSession(busy.id); !ok {
+		t.Errorf("busy game removed")
+	}
+	if _, ok := getSession(idle.id); ok {
+		t.Errorf("idle game kept")
+	}
+	expireSessions(time.Now().Add(sessionIdleTime + time.Second))
+	if _, ok := getSession(busy.id); ok {
+		t.Errorf("idle game kept once no longer busy")
+	}
+}
+
+func TestSessionMoveErrors(t *testing.T) {
+	s := newTestSession(t, `{"sims":1}`)
+	for _, body := range []string{`{"move":[0,40]}`, `{"move":[-1,2]}`, `{"move":[16,0]}`, `{"move":[0,0]}`, `{"notation":"Z9"}`} {
+		req := httptest.NewRequest("POST", "/games/"+s.id+"/move", strings.NewReader(body))
+		req = mux.SetURLVars(req, map[string]string{"id": s.id})
+		rec := httptest.NewRecorder()
+		SessionMoveAPI(rec, req)
+		if rec.Code != http.StatusBadRequest {
+			t.Errorf("%s: status %d, want %d", body, rec.Code, http.StatusBadRequest)
+		}
+	}
+}
+
+func TestPonderLimit(t *testing.T) {
+	// No more than maxPonderSearches background searches run at once
+	game := newGame()
+	running := []*ponderer{}
+	for k := 0; k < maxPonderSearches; k++ {
+		pd := startPonder(game, 1, time.Minute, DefaultParams)
+		if pd == nil {
+			t.Fatalf("search %d not started", k+1)
+		}
+		running = append(running, pd)
+	}
+	if pd := startPonder(game, 1, time.Minute, DefaultParams); pd != nil {
+		pd.Stop()
+		t.Errorf("more than %d searches started", maxPonderSearches)
+	}
+	running[0].Stop()
+	pd := startPonder(game, 1, time.Minute, DefaultParams)
+	if pd == nil {
+		t.Errorf("no search started after one stopped")
+	} else {
+		running[0] = pd
+	}
+	for _, pd := range running {
+		pd.Stop()
+	}
+}
@@ -1,5 +1,7 @@
+//go:build lambda
+
 // Alternative compilation for deploying as an AWS lambda function
-// > go build lambda.go reversi.go
+// > go build -tags lambda -o lambda
 // > zip function.zip lambda
 // Upload to AWS Lambda console accordingly
 
@@ -11,21 +13,28 @@ import (
 
 func HandleLambdaEvent(state GameState) (DecisionResponse, error) {
 
-	game := SetGame(state)
+	game, err := LoadGame(state)
+	if err != nil {
+		return DecisionResponse{}, err
+	}
+	colour := game.turn
 	root := Node{
-		state: game,
+		state: game.compact(),
 		depth: 0,
 	}
 
-	decision := Search(root, 20, 300)
+	p := DefaultParams
+	p.FinalPolicy = state.Policy
+	decision, policy := SearchDecision(root, 20, 300, p)
 	game.Move(decision)
 
 	response := DecisionResponse{
 		Move:       [2]int{decision.i, decision.j},
-		Colour:     state.Turn,
+		Colour:     colour,
 		Turn:       game.turn,
 		BlackScore: game.blackScore,
 		WhiteScore: game.whiteScore,
+		Policy:     policy,
 	}
 
 	return response, nil
@@ -1,21 +1,73 @@
+//go:build !lambda
+
 package main
 
 import (
 	"fmt"
 	"log"
 	"net/http"
+	"os"
+	"sort"
+	"time"
 
 	"github.com/gorilla/mux"
 )
 
+// Commands that can be run from the command line instead of the server
+// i.e. ./reversi-monte-carlo-tree-search <command> [flags] [args]
+var commands = map[string]func(args []string) error{
+	"bench":      benchCommand,
+	"book":       bookCommand,
+	"network":    networkCommand,
+	"newgame":    newGameCommand,
+	"patterns":   patternsCommand,
+	"perft":      perftCommand,
+	"rating":     ratingCommand,
+	"rolit":      rolitCommand,
+	"search":     searchCommand,
+	"selfplay":   selfPlayCommand,
+	"sprt":       sprtCommand,
+	"tournament": tournamentCommand,
+	"tune":       tuneCommand,
+	"wthor":      wthorCommand,
+}
+
 func main() {
-	// Simulator(100, 10, 100)
+	if len(os.Args) > 1 {
+		runCommand(os.Args[1], os.Args[2:])
+		return
+	}
 	fmt.Println("Running revers-mcts application...")
 	fmt.Println("Application is running at: http://localhost:8080")
 	router := mux.NewRouter()
 	s := http.StripPrefix("/static/", http.FileServer(http.Dir("./static/")))
 	router.HandleFunc("/", Index)
 	router.HandleFunc("/search_move", GameStateAPI)
+	router.HandleFunc("/new_game", NewGameAPI)
+	router.HandleFunc("/rolit_move", RolitAPI)
+	router.HandleFunc("/games", NewSessionAPI).Methods("POST")
+	router.HandleFunc("/games/{id}", SessionAPI).Methods("GET")
+	router.HandleFunc("/games/{id}/move", SessionMoveAPI).Methods("POST")
 	router.PathPrefix("/static/").Handler(s)
+	go expireSessionsEvery(time.Minute)
 	log.Fatal(http.ListenAndServe(":8080", router))
 }
+
+func runCommand(name string, args []string) {
+	// Run the named command with the remaining command line arguments
+	// Exits with a non-zero status if the command is unknown or fails
+	command, ok := commands[name]
+	if !ok {
+		names := []string{}
+		for n := range commands {
+			names = append(names, n)
+		}
+		sort.Strings(names)
+		fmt.Fprintf(os.Stderr, "Unknown command %q. Available commands: %v\n", name, names)
+		os.Exit(2)
+	}
+	if err := command(args); err != nil {
+		fmt.Fprintln(os.Stderr, "error:", err)
+		os.Exit(1)
+	}
+}
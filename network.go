@@ -0,0 +1,458 @@
+// Neural network evaluation of positions on the CPU
+// A small convolutional network with a policy and a value head,
+// as in AlphaZero, evaluated in pure Go without any dependencies
+// The weights are read from a JSON file
+// The value is used to evaluate leaves instead of (or mixed with) rollouts
+// and the policy as prior for PUCT selection
+
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"math"
+	"math/rand"
+	"os"
+	"sort"
+	"sync"
+)
+
+// Input planes of the network, each boardSize x boardSize
+// 0: pieces of the side to move
+// 1: pieces of the opponent
+// 2: valid moves of the side to move
+// 3: blocked squares
+const networkPlanes = 4
+
+type Layer struct {
+
+	// Struct to hold a layer of the network
+	// Convolutions keep the board size (same padding, stride 1)
+	// Dense layers take all outputs of the previous layer as inputs
+
+	Type       string    `json:"type"`             // "conv" or "dense"
+	In         int       `json:"in"`               // Input channels (conv) or inputs (dense)
+	Out        int       `json:"out"`              // Output channels (conv) or outputs (dense)
+	Kernel     int       `json:"kernel,omitempty"` // Odd kernel size of convolutions
+	Activation string    `json:"activation"`       // "relu", "tanh" or "" for none
+	Weights    []float64 `json:"weights"`          // conv: [out][in][kernel][kernel], dense: [out][in]
+	Bias       []float64 `json:"bias"`             // [out]
+}
+
+type Network struct {
+
+	// Struct to hold the network read from a weights file
+	// The trunk is shared by both heads
+
+	BoardSize int     `json:"boardSize"`
+	Trunk     []Layer `json:"trunk"`
+	Policy    []Layer `json:"policy"` // Outputs boardSize x boardSize move logits
+	Value     []Layer `json:"value"`  // Outputs the value for the side to move, in [-1, 1]
+}
+
+// Activations of a layer, channels x size x size
+// Outputs of dense layers have size 1
+type tensor struct {
+	channels int
+	size     int
+	data     []float64
+}
+
+func (l Layer) check(in tensor) (tensor, error) {
+	// Check the layer against its input and return the shape of its output
+	switch l.Type {
+	case "conv":
+		if in.size == 1 {
+			return tensor{}, errors.New("conv layer after a dense layer")
+		}
+		if l.In != in.channels {
+			return tensor{}, fmt.Errorf("conv layer has %d input channels, expected %d", l.In, in.channels)
+		}
+		if l.Kernel < 1 || l.Kernel%2 == 0 {
+			return tensor{}, fmt.Errorf("conv kernel must be odd, got %d", l.Kernel)
+		}
+		if len(l.Weights) != l.Out*l.In*l.Kernel*l.Kernel {
+			return tensor{}, fmt.Errorf("conv layer has %d weights, expected %d", len(l.Weights), l.Out*l.In*l.Kernel*l.Kernel)
+		}
+		in = tensor{channels: l.Out, size: in.size}
+	case "dense":
+		if l.In != in.channels*in.size*in.size {
+			return tensor{}, fmt.Errorf("dense layer has %d inputs, expected %d", l.In, in.channels*in.size*in.size)
+		}
+		if len(l.Weights) != l.Out*l.In {
+			return tensor{}, fmt.Errorf("dense layer has %d weights, expected %d", len(l.Weights), l.Out*l.In)
+		}
+		in = tensor{channels: l.Out, size: 1}
+	default:
+		return tensor{}, fmt.Errorf("unknown layer type %q", l.Type)
+	}
+	if len(l.Bias) != l.Out {
+		return tensor{}, fmt.Errorf("%s layer has %d biases, expected %d", l.Type, len(l.Bias), l.Out)
+	}
+	switch l.Activation {
+	case "", "relu", "tanh":
+	default:
+		return tensor{}, fmt.Errorf("unknown activation %q", l.Activation)
+	}
+	return in, nil
+}
+
+func (l Layer) forward(in tensor) tensor {
+	out := tensor{channels: l.Out, size: in.size}
+	if l.Type == "dense" {
+		out.size = 1
+	}
+	out.data = make([]float64, out.channels*out.size*out.size)
+	area := in.size * in.size
+	if l.Type == "conv" {
+		k := l.Kernel
+		pad := k / 2
+		for o := 0; o < l.Out; o++ {
+			for i := 0; i < in.size; i++ {
+				for j := 0; j < in.size; j++ {
+					sum := l.Bias[o]
+					for c := 0; c < l.In; c++ {
+						w := l.Weights[(o*l.In+c)*k*k:]
+						x := in.data[c*area:]
+						for di := 0; di < k; di++ {
+							ii := i + di - pad
+							if ii < 0 || ii >= in.size {
+								continue
+							}
+							for dj := 0; dj < k; dj++ {
+								jj := j + dj - pad
+								if jj < 0 || jj >= in.size {
+									continue
+								}
+								sum += w[di*k+dj] * x[ii*in.size+jj]
+							}
+						}
+					}
+					out.data[o*area+i*in.size+j] = sum
+				}
+			}
+		}
+	} else {
+		for o := 0; o < l.Out; o++ {
+			sum := l.Bias[o]
+			w := l.Weights[o*l.In : (o+1)*l.In]
+			for k, x := range in.data {
+				sum += w[k] * x
+			}
+			out.data[o] = sum
+		}
+	}
+	for k, x := range out.data {
+		switch l.Activation {
+		case "relu":
+			out.data[k] = math.Max(x, 0)
+		case "tanh":
+			out.data[k] = math.Tanh(x)
+		}
+	}
+	return out
+}
+
+func (net *Network) check() error {
+	// Check that the layers fit together and the heads have the right outputs
+	if err := validBoardSize(net.BoardSize); err != nil {
+		return err
+	}
+	trunk := tensor{channels: networkPlanes, size: net.BoardSize}
+	var err error
+	for k, l := range net.Trunk {
+		if trunk, err = l.check(trunk); err != nil {
+			return fmt.Errorf("trunk layer %d: %v", k, err)
+		}
+	}
+	heads := []struct {
+		name    string
+		layers  []Layer
+		outputs int
+	}{
+		{"policy", net.Policy, net.BoardSize * net.BoardSize},
+		{"value", net.Value, 1},
+	}
+	for _, head := range heads {
+		out := trunk
+		for k, l := range head.layers {
+			if out, err = l.check(out); err != nil {
+				return fmt.Errorf("%s layer %d: %v", head.name, k, err)
+			}
+		}
+		if n := out.channels * out.size * out.size; n != head.outputs {
+			return fmt.Errorf("%s head has %d outputs, expected %d", head.name, n, head.outputs)
+		}
+	}
+	return nil
+}
+
+func LoadNetwork(path string) (*Network, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	net := &Network{}
+	if err := json.Unmarshal(b, net); err != nil {
+		return nil, fmt.Errorf("%s: %v", path, err)
+	}
+	if err := net.check(); err != nil {
+		return nil, fmt.Errorf("%s: %v", path, err)
+	}
+	return net, nil
+}
+
+func (net *Network) Save(path string) error {
+	b, err := json.Marshal(net)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, b, 0644)
+}
+
+// Networks already read, by path
+// Agents refer to networks by the path of their weights
+var networks = struct {
+	sync.Mutex
+	loaded map[string]*Network
+}{loaded: map[string]*Network{}}
+
+func cachedNetwork(path string) (*Network, error) {
+	// Read the network from path, only once for every path
+	networks.Lock()
+	defer networks.Unlock()
+	if net, ok := networks.loaded[path]; ok {
+		return net, nil
+	}
+	net, err := LoadNetwork(path)
+	if err != nil {
+		return nil, err
+	}
+	networks.loaded[path] = net
+	return net, nil
+}
+
+func networkInput(game Board) tensor {
+	// Input planes of game from the perspective of the side to move
+	size := game.length
+	area := size * size
+	x := tensor{channels: networkPlanes, size: size, data: make([]float64, networkPlanes*area)}
+	for i := 0; i < size; i++ {
+		for j := 0; j < size; j++ {
+			switch int(game.board[i][j]) {
+			case game.turn:
+				x.data[i*size+j] = 1
+			case -game.turn:
+				x.data[area+i*size+j] = 1
+			case blockedSquare:
+				x.data[3*area+i*size+j] = 1
+			}
+		}
+	}
+	for _, move := range game.validSpace {
+		x.data[2*area+move.i*size+move.j] = 1
+	}
+	return x
+}
+
+func (net *Network) Evaluate(game Board) (float64, []float64) {
+	// Value of game for the side to move, in [-1, 1],
+	// and the probabilities of the valid moves in the order of game.validSpace
+	// The board has to be of the size of the network
+	// Networks are trained on standard games, in the misere variant
+	// the value is negated and the policy logits are flipped
+	x := networkInput(game)
+	for _, l := range net.Trunk {
+		x = l.forward(x)
+	}
+	policy := x
+	for _, l := range net.Policy {
+		policy = l.forward(policy)
+	}
+	value := x
+	for _, l := range net.Value {
+		value = l.forward(value)
+	}
+	v := math.Max(-1, math.Min(1, value.data[0]))
+	logits := make([]float64, len(game.validSpace))
+	for k, move := range game.validSpace {
+		logits[k] = policy.data[move.i*game.length+move.j]
+		if game.misere {
+			logits[k] = -logits[k]
+		}
+	}
+	if game.misere {
+		v = -v
+	}
+	return v, softmax(logits)
+}
+
+// Prior from the policy head of a network
+// Boards of other sizes than the network get the uniform prior
+type networkPrior struct {
+	net *Network
+}
+
+func (np networkPrior) Priors(game Board) []float64 {
+	if game.length != np.net.BoardSize {
+		return uniformPrior{}.Priors(game)
+	}
+	_, priors := np.net.Evaluate(game)
+	return priors
+}
+
+func (net *Network) LeafValue(game Board) (float64, bool) {
+	// Value of game for the side to move, for leaf evaluation
+	if game.length != net.BoardSize {
+		return 0, false
+	}
+	v, _ := net.Evaluate(game)
+	return v, true
+}
+
+type leafEvaluator interface {
+	// Value of game for the side to move, in [-1, 1]
+	// false if the evaluator cannot score the board, e.g. for its size
+	LeafValue(game Board) (float64, bool)
+}
+
+func leafEvaluatorFor(p Params) leafEvaluator {
+	// Evaluator of the search parameters, the network if both
+	// a network and pattern weights are given, nil for rollouts only
+	if p.Network != "" {
+		if net, err := cachedNetwork(p.Network); err == nil {
+			return net
+		}
+	}
+	if p.Patterns != "" {
+		if pw, err := cachedPatternWeights(p.Patterns); err == nil {
+			return pw
+		}
+	}
+	return nil
+}
+
+func evaluateLeaf(game Board, nSims int, p Params, amaf *amafCounts, eval leafEvaluator) (int, int) {
+	// Evaluate a leaf as wins and losses out of nSims for its side to move
+	// by rollouts, the evaluator or a mix of both weighted by p.ValueWeight
+	// A value v counts as nSims games with a win rate of (1 + v) / 2
+	// Finished games and boards the evaluator cannot score use rollouts only
+	// With an evaluator, rollout draws count as half a win and half a loss
+	// like its value of 0, so the values its training targets come from
+	// do not count draws as losses
+	v, ok := 0.0, false
+	valued := eval != nil && p.ValueWeight > 0
+	if valued && game.winner == 0 {
+		v, ok = eval.LeafValue(game)
+	}
+	if !ok {
+		wins, loss, draws, _ := Rollout(game, nSims, p, amaf)
+		if valued {
+			wins, loss = wins+draws/2, loss+draws-draws/2
+		}
+		return wins, loss
+	}
+	wins := p.ValueWeight * float64(nSims) * (1 + v) / 2
+	loss := p.ValueWeight * float64(nSims) * (1 - v) / 2
+	if p.ValueWeight < 1 {
+		w, l, d, _ := Rollout(game, nSims, p, amaf)
+		wins += (1 - p.ValueWeight) * (float64(w) + float64(d)/2)
+		loss += (1 - p.ValueWeight) * (float64(l) + float64(d)/2)
+	} else if amaf != nil {
+
+		// Only the moves in the tree count for RAVE without rollouts
+		amaf.reset()
+		if game.turn == 1 {
+			amaf.blackWins, amaf.whiteWins = int(math.Round(wins)), int(math.Round(loss))
+		} else {
+			amaf.blackWins, amaf.whiteWins = int(math.Round(loss)), int(math.Round(wins))
+		}
+	}
+	return int(math.Round(wins)), int(math.Round(loss))
+}
+
+func randomNetwork(r *rand.Rand, size int, filters int, blocks int) *Network {
+	// Network with random weights (He initialisation) from r for experiments
+	// blocks 3x3 convolutions with filters channels in the trunk
+	layer := func(kind string, in int, out int, kernel int, activation string) Layer {
+		fanIn := in
+		n := out * in
+		if kind == "conv" {
+			fanIn *= kernel * kernel
+			n *= kernel * kernel
+		}
+		l := Layer{Type: kind, In: in, Out: out, Kernel: kernel, Activation: activation,
+			Weights: make([]float64, n), Bias: make([]float64, out)}
+		for k := range l.Weights {
+			l.Weights[k] = r.NormFloat64() * math.Sqrt(2/float64(fanIn))
+		}
+		return l
+	}
+	net := &Network{BoardSize: size}
+	in := networkPlanes
+	for b := 0; b < blocks; b++ {
+		net.Trunk = append(net.Trunk, layer("conv", in, filters, 3, "relu"))
+		in = filters
+	}
+	net.Policy = []Layer{layer("conv", in, 1, 1, "")}
+	net.Value = []Layer{
+		layer("conv", in, 1, 1, "relu"),
+		layer("dense", size*size, 32, 0, "relu"),
+		layer("dense", 32, 1, 0, "tanh"),
+	}
+	return net
+}
+
+func networkCommand(args []string) error {
+	// Create network weights or evaluate a position with a network
+	// Example:
+	//     > reversi network -init -filters 32 -blocks 4 -weights net.json
+	//     > reversi network -weights net.json -position "---------------------------OX------XO--------------------------- X"
+	fs := flag.NewFlagSet("network", flag.ExitOnError)
+	weights := fs.String("weights", "network.json", "weights file of the network")
+	create := fs.Bool("init", false, "write a network with random weights instead of evaluating")
+	filters := fs.Int("filters", 32, "channels of the convolutions in the trunk, with -init")
+	blocks := fs.Int("blocks", 4, "number of convolutions in the trunk, with -init")
+	position := fs.String("position", "", "position string to evaluate (default start position)")
+	start := StartOptions{}
+	start.addFlags(fs)
+	fs.Parse(args)
+
+	if *create {
+		if err := validBoardSize(start.BoardSize); err != nil {
+			return err
+		}
+		if *filters < 1 || *blocks < 1 {
+			return errors.New("filters and blocks must be at least 1")
+		}
+		return randomNetwork(rand.New(rand.NewSource(rand.Int63())), start.BoardSize, *filters, *blocks).Save(*weights)
+	}
+	net, err := LoadNetwork(*weights)
+	if err != nil {
+		return err
+	}
+	if *position != "" {
+		start.Position = *position
+	}
+	game, err := start.Board()
+	if err != nil {
+		return err
+	}
+	if game.length != net.BoardSize {
+		return fmt.Errorf("network is for %dx%d boards, position is %dx%d", net.BoardSize, net.BoardSize, game.length, game.length)
+	}
+	game.Show()
+	value, priors := net.Evaluate(game)
+	fmt.Printf("Value: %+.3f\n", value)
+	order := make([]int, len(priors))
+	for k := range order {
+		order[k] = k
+	}
+	sort.SliceStable(order, func(a, b int) bool { return priors[order[a]] > priors[order[b]] })
+	for _, k := range order {
+		fmt.Printf("%-4s %.3f\n", game.validSpace[k].Notation(), priors[k])
+	}
+	return nil
+}
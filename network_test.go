@@ -0,0 +1,98 @@
+package main
+
+import (
+	"math"
+	"math/rand"
+	"path/filepath"
+	"testing"
+)
+
+// Evaluator giving every board the same value
+type constantEvaluator float64
+
+func (c constantEvaluator) LeafValue(game Board) (float64, bool) {
+	return float64(c), true
+}
+
+func TestEvaluateLeafDraw(t *testing.T) {
+	// A drawn game counts as half a win with an evaluator, as its value of 0
+	game := mustParseBoard(t, "XXXXXXXXOOOOOOOO X")
+	game.winner = game.determineWinner()
+	if game.winner != 99 {
+		t.Fatalf("winner %d, want a draw", game.winner)
+	}
+	p := DefaultParams
+	for _, weight := range []float64{0.5, 1} {
+		p.ValueWeight = weight
+		if wins, loss := evaluateLeaf(game, 10, p, nil, constantEvaluator(1)); wins != 5 || loss != 5 {
+			t.Errorf("value weight %.1f: %d wins and %d losses, want 5 and 5", weight, wins, loss)
+		}
+	}
+}
+
+func TestNetworkInitSize(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "net.json")
+	if err := networkCommand([]string{"-init", "-size", "7", "-weights", path}); err == nil {
+		t.Errorf("network created for a 7x7 board")
+	}
+	if err := networkCommand([]string{"-init", "-size", "6", "-filters", "2", "-blocks", "1", "-weights", path}); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestEvaluatorsMisere(t *testing.T) {
+	// In the misere variant values are negated and the prior order is reversed
+	r := rand.New(rand.NewSource(1))
+	pw := newPatternWeights(1, patternTargetDiscs)
+	for _, table := range pw.Weights[0] {
+		for k := range table {
+			table[k] = r.NormFloat64()
+		}
+	}
+	net := randomNetwork(r, 8, 4, 1)
+	game, err := StartOptions{Moves: "F5D6C3D3C4"}.Board()
+	if err != nil {
+		t.Fatal(err)
+	}
+	misere := game
+	misere.misere = true
+
+	argmax := func(priors []float64) int {
+		best := 0
+		for k := range priors {
+			if priors[k] > priors[best] {
+				best = k
+			}
+		}
+		return best
+	}
+	argmin := func(priors []float64) int {
+		best := 0
+		for k := range priors {
+			if priors[k] < priors[best] {
+				best = k
+			}
+		}
+		return best
+	}
+	cases := []struct {
+		name  string
+		eval  leafEvaluator
+		prior PriorProvider
+	}{
+		{"patterns", pw, patternPrior{pw}},
+		{"network", net, networkPrior{net}},
+	}
+	for _, tc := range cases {
+		v, _ := tc.eval.LeafValue(game)
+		vm, _ := tc.eval.LeafValue(misere)
+		if v == 0 || math.Abs(v+vm) > 1e-9 {
+			t.Errorf("%s: value %f, misere %f", tc.name, v, vm)
+		}
+		priors := tc.prior.Priors(game)
+		if k := argmax(tc.prior.Priors(misere)); k != argmin(priors) {
+			t.Errorf("%s: misere prefers %s, standard prefers %s least", tc.name,
+				game.validSpace[k].Notation(), game.validSpace[argmin(priors)].Notation())
+		}
+	}
+}
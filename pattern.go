@@ -0,0 +1,500 @@
+// Pattern evaluation of 8x8 positions
+// The board is split into patterns (edges, corners, lines and diagonals)
+// and every configuration of a pattern has a weight per game phase
+// The score of a position is the sum of the weights of its patterns
+// Weights are fitted offline to labelled positions from WTHOR files
+// or self-play records, as in Logistello, by logistic regression on the
+// result of the game, the score being the log-odds of a win for the side
+// to move, or by least squares on the final disc difference, the score
+// being an estimate of it
+
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"math"
+	"math/rand"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// Patterns are only defined for the standard board
+const patternBoardSize = 8
+
+// Targets the weights can be fitted to
+const (
+	patternTargetResult = "result" // Win (1), draw (0.5) or loss (0) by logistic regression
+	patternTargetDiscs  = "discs"  // Final disc difference by least squares
+)
+
+// Disc difference at which the value of a position is tanh(1)
+const patternValueScale = 8.0
+
+// Disc difference between moves that changes their prior by a factor of e
+const patternPriorScale = 4.0
+
+type patternDef struct {
+	name    string
+	squares []Position
+}
+
+func patternLine(i0 int, j0 int, di int, dj int, n int) []Position {
+	line := []Position{}
+	for k := 0; k < n; k++ {
+		line = append(line, Position{i0 + k*di, j0 + k*dj})
+	}
+	return line
+}
+
+// Patterns of the evaluation, given for one corner or edge of the board
+// All symmetries of a pattern share its weights
+var patternDefs = []patternDef{
+	{"hv2", patternLine(1, 0, 0, 1, 8)},
+	{"hv3", patternLine(2, 0, 0, 1, 8)},
+	{"hv4", patternLine(3, 0, 0, 1, 8)},
+	{"d4", patternLine(0, 3, 1, -1, 4)},
+	{"d5", patternLine(0, 4, 1, -1, 5)},
+	{"d6", patternLine(0, 5, 1, -1, 6)},
+	{"d7", patternLine(0, 6, 1, -1, 7)},
+	{"d8", patternLine(0, 7, 1, -1, 8)},
+	{"edge2x", append(patternLine(0, 0, 0, 1, 8), Position{1, 1}, Position{1, 6})},
+	{"corner3x3", append(append(patternLine(0, 0, 0, 1, 3), patternLine(1, 0, 0, 1, 3)...), patternLine(2, 0, 0, 1, 3)...)},
+	{"corner2x5", append(patternLine(0, 0, 0, 1, 5), patternLine(1, 0, 0, 1, 5)...)},
+}
+
+type patternInstance struct {
+	pattern int        // Index in patternDefs
+	squares []Position // Squares of the pattern mapped by one symmetry
+}
+
+// Every distinct placement of every pattern on the board
+var patternInstances = []patternInstance{}
+
+func init() {
+	for k, def := range patternDefs {
+		seen := map[string]bool{}
+		for sym := 0; sym < symmetries; sym++ {
+			squares := []Position{}
+			keys := []string{}
+			for _, s := range def.squares {
+				t := s.transform(patternBoardSize, sym)
+				squares = append(squares, t)
+				keys = append(keys, t.Notation())
+			}
+			sort.Strings(keys)
+			if key := strings.Join(keys, ""); !seen[key] {
+				seen[key] = true
+				patternInstances = append(patternInstances, patternInstance{k, squares})
+			}
+		}
+	}
+}
+
+type PatternWeights struct {
+
+	// Struct to hold the weights of the pattern evaluation
+	// Read from and written to a JSON file
+
+	Patterns []string      `json:"patterns"` // Names of the patterns, in the order of the weights
+	Target   string        `json:"target"`   // Target the weights are fitted to, see patternTargetResult, discs if empty
+	Phases   int           `json:"phases"`   // Game phases, by number of discs on the board
+	Bias     []float64     `json:"bias"`     // [phase]
+	Weights  [][][]float64 `json:"weights"`  // [phase][pattern][configuration]
+}
+
+func newPatternWeights(phases int, target string) *PatternWeights {
+	pw := &PatternWeights{Target: target, Phases: phases, Bias: make([]float64, phases)}
+	for _, def := range patternDefs {
+		pw.Patterns = append(pw.Patterns, def.name)
+	}
+	for phase := 0; phase < phases; phase++ {
+		tables := [][]float64{}
+		for _, def := range patternDefs {
+			tables = append(tables, make([]float64, int(math.Pow(3, float64(len(def.squares))))))
+		}
+		pw.Weights = append(pw.Weights, tables)
+	}
+	return pw
+}
+
+func (pw *PatternWeights) check() error {
+	if pw.Target != "" && pw.Target != patternTargetResult && pw.Target != patternTargetDiscs {
+		return fmt.Errorf("unknown target %q, expected %s or %s", pw.Target, patternTargetResult, patternTargetDiscs)
+	}
+	if pw.Phases < 1 || len(pw.Bias) != pw.Phases || len(pw.Weights) != pw.Phases {
+		return fmt.Errorf("weights for %d phases expected", pw.Phases)
+	}
+	if len(pw.Patterns) != len(patternDefs) {
+		return fmt.Errorf("%d patterns, expected %d", len(pw.Patterns), len(patternDefs))
+	}
+	for k, def := range patternDefs {
+		if pw.Patterns[k] != def.name {
+			return fmt.Errorf("pattern %d is %q, expected %q", k, pw.Patterns[k], def.name)
+		}
+		for phase := 0; phase < pw.Phases; phase++ {
+			if len(pw.Weights[phase][k]) != int(math.Pow(3, float64(len(def.squares)))) {
+				return fmt.Errorf("pattern %s has the wrong number of weights in phase %d", def.name, phase)
+			}
+		}
+	}
+	return nil
+}
+
+func LoadPatternWeights(path string) (*PatternWeights, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	pw := &PatternWeights{}
+	if err := json.Unmarshal(b, pw); err != nil {
+		return nil, fmt.Errorf("%s: %v", path, err)
+	}
+	if err := pw.check(); err != nil {
+		return nil, fmt.Errorf("%s: %v", path, err)
+	}
+	return pw, nil
+}
+
+func (pw *PatternWeights) Save(path string) error {
+	b, err := json.Marshal(pw)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, b, 0644)
+}
+
+// Pattern weights already read, by path
+var patternWeights = struct {
+	sync.Mutex
+	loaded map[string]*PatternWeights
+}{loaded: map[string]*PatternWeights{}}
+
+func cachedPatternWeights(path string) (*PatternWeights, error) {
+	// Read the pattern weights from path, only once for every path
+	patternWeights.Lock()
+	defer patternWeights.Unlock()
+	if pw, ok := patternWeights.loaded[path]; ok {
+		return pw, nil
+	}
+	pw, err := LoadPatternWeights(path)
+	if err != nil {
+		return nil, err
+	}
+	patternWeights.loaded[path] = pw
+	return pw, nil
+}
+
+func (pw *PatternWeights) phase(game Board) int {
+	discs := game.blackScore + game.whiteScore
+	phase := (discs - 4) * pw.Phases / 61
+	return int(math.Max(0, math.Min(float64(phase), float64(pw.Phases-1))))
+}
+
+func patternIndexes(game Board) []int {
+	// Configuration of every pattern instance, in base 3
+	// Empty (and blocked) squares are 0, the side to move 1, the opponent 2
+	indexes := make([]int, len(patternInstances))
+	for k, inst := range patternInstances {
+		index := 0
+		for _, s := range inst.squares {
+			index *= 3
+			switch int(game.board[s.i][s.j]) {
+			case game.turn:
+				index += 1
+			case -game.turn:
+				index += 2
+			}
+		}
+		indexes[k] = index
+	}
+	return indexes
+}
+
+func (pw *PatternWeights) score(phase int, indexes []int) float64 {
+	score := pw.Bias[phase]
+	for k, index := range indexes {
+		score += pw.Weights[phase][patternInstances[k].pattern][index]
+	}
+	return score
+}
+
+func (pw *PatternWeights) Score(game Board) float64 {
+	// Log-odds of a win, or estimated final disc difference, for the side to move
+	// The board has to be 8x8
+	return pw.score(pw.phase(game), patternIndexes(game))
+}
+
+func (pw *PatternWeights) logistic() bool {
+	return pw.Target == patternTargetResult
+}
+
+func (pw *PatternWeights) value(score float64) float64 {
+	// Value in [-1, 1] of a score
+	// For log-odds this is the win probability mapped to [-1, 1]
+	if pw.logistic() {
+		return math.Tanh(score / 2)
+	}
+	return math.Tanh(score / patternValueScale)
+}
+
+func (pw *PatternWeights) LeafValue(game Board) (float64, bool) {
+	// Value of game for the side to move, in [-1, 1], for leaf evaluation
+	// In the misere variant fewer discs are better and the value is negated
+	if game.length != patternBoardSize {
+		return 0, false
+	}
+	v := pw.value(pw.Score(game))
+	if game.misere {
+		v = -v
+	}
+	return v, true
+}
+
+// Prior from the pattern scores of the positions after each move
+// Boards of other sizes get the uniform prior
+// In the misere variant moves leaving fewer discs get the higher prior
+type patternPrior struct {
+	pw *PatternWeights
+}
+
+func (pp patternPrior) Priors(game Board) []float64 {
+	if game.length != patternBoardSize {
+		return uniformPrior{}.Priors(game)
+	}
+	logits := make([]float64, len(game.validSpace))
+	for k, move := range game.validSpace {
+		child := game
+		child.Move(move)
+		score := pp.pw.Score(child)
+		if child.turn != game.turn {
+			score = -score
+		}
+		if game.misere {
+			score = -score
+		}
+		if pp.pw.logistic() {
+			logits[k] = score
+		} else {
+			logits[k] = score / patternPriorScale
+		}
+	}
+	return softmax(logits)
+}
+
+type patternSample struct {
+	phase   int
+	indexes []int
+	result  float64 // Result for the side to move: win (1), draw (0.5), loss (0)
+	discs   float64 // Final disc difference for the side to move
+}
+
+func resultOf(discs float64) float64 {
+	// Result for a side from its final disc difference
+	switch {
+	case discs > 0:
+		return 1
+	case discs < 0:
+		return 0
+	}
+	return 0.5
+}
+
+func patternSamplesWthor(path string, phases int) ([]patternSample, error) {
+	// Every position of the games of a WTHOR file, labelled with the final disc difference
+	// and the result
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	wr, err := NewWthorReader(f)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", path, err)
+	}
+	pw := PatternWeights{Phases: phases}
+	samples := []patternSample{}
+	for {
+		g, err := wr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("%s: %v", path, err)
+		}
+		end, err := g.Replay()
+		if err != nil {
+			continue
+		}
+		diff := float64(end.blackScore - end.whiteScore)
+		game := newGame()
+		for _, move := range g.Moves {
+			discs := diff * float64(game.turn)
+			samples = append(samples, patternSample{pw.phase(game), patternIndexes(game), resultOf(discs), discs})
+			game.Move(move)
+		}
+	}
+	return samples, nil
+}
+
+func patternSamplesSelfPlay(path string, phases int) ([]patternSample, error) {
+	// The 8x8 positions of a self-play file, labelled with the final disc difference
+	// and the result
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	pw := PatternWeights{Phases: phases}
+	samples := []patternSample{}
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 1024*1024), 1024*1024)
+	for line := 1; scanner.Scan(); line++ {
+		r := SelfPlayRecord{}
+		if err := json.Unmarshal(scanner.Bytes(), &r); err != nil {
+			return nil, fmt.Errorf("%s:%d: %v", path, line, err)
+		}
+		game, err := ParseBoard(r.Position)
+		if err != nil {
+			return nil, fmt.Errorf("%s:%d: %v", path, line, err)
+		}
+		if game.length == patternBoardSize {
+			samples = append(samples, patternSample{pw.phase(game), patternIndexes(game), float64(r.Result+1) / 2, float64(r.Score)})
+		}
+	}
+	return samples, scanner.Err()
+}
+
+func (pw *PatternWeights) train(samples []patternSample, epochs int, rate float64, progress func(epoch int, loss float64)) {
+	// Fit the weights to the samples by stochastic gradient descent
+	// For the result target on the log loss of the predicted win probability,
+	// reported as the mean log loss, otherwise on the squared error of the
+	// predicted disc difference, reported as the root mean squared error
+	// Both have the gradient target - prediction for the score
+	order := rand.Perm(len(samples))
+	for epoch := 1; epoch <= epochs; epoch++ {
+		rand.Shuffle(len(order), func(a, b int) {
+			order[a], order[b] = order[b], order[a]
+		})
+		sum := 0.0
+		for _, k := range order {
+			s := samples[k]
+			score := pw.score(s.phase, s.indexes)
+			var err float64
+			if pw.logistic() {
+				p := 1 / (1 + math.Exp(-score))
+				err = s.result - p
+				sum -= s.result*math.Log(math.Max(p, 1e-15)) + (1-s.result)*math.Log(math.Max(1-p, 1e-15))
+			} else {
+				err = s.discs - score
+				sum += err * err
+			}
+			step := rate * err
+			pw.Bias[s.phase] += step
+			for i, index := range s.indexes {
+				pw.Weights[s.phase][patternInstances[i].pattern][index] += step
+			}
+		}
+		if progress != nil {
+			if pw.logistic() {
+				progress(epoch, sum/float64(len(samples)))
+			} else {
+				progress(epoch, math.Sqrt(sum/float64(len(samples))))
+			}
+		}
+	}
+}
+
+func patternsCommand(args []string) error {
+	// Train pattern weights or evaluate a position with them
+	// Example:
+	//     > reversi patterns -train -wthor WTH_2019.wtb,WTH_2020.wtb -selfplay selfplay.jsonl -weights patterns.json
+	//     > reversi patterns -weights patterns.json -position "---------------------------OX------XO--------------------------- X"
+	fs := flag.NewFlagSet("patterns", flag.ExitOnError)
+	weights := fs.String("weights", "patterns.json", "weights file of the pattern evaluation")
+	train := fs.Bool("train", false, "train the weights instead of evaluating")
+	wthor := fs.String("wthor", "", "comma separated WTHOR files to train on")
+	selfplay := fs.String("selfplay", "", "comma separated self-play files to train on")
+	phases := fs.Int("phases", 6, "number of game phases with their own weights")
+	target := fs.String("target", patternTargetResult, "fit the result of the game by logistic regression (result) or the final disc difference by least squares (discs)")
+	epochs := fs.Int("epochs", 10, "passes over the training positions")
+	rate := fs.Float64("rate", 0, "learning rate (default 0.01 for result, 0.002 for discs)")
+	position := fs.String("position", "", "position string to evaluate (default start position)")
+	fs.Parse(args)
+
+	if *train {
+		if *phases < 1 || *phases > 61 {
+			return errors.New("phases must be from 1 to 61")
+		}
+		pw := newPatternWeights(*phases, *target)
+		if err := pw.check(); err != nil {
+			return err
+		}
+		if *rate == 0 {
+			*rate = 0.002
+			if pw.logistic() {
+				*rate = 0.01
+			}
+		}
+		samples := []patternSample{}
+		sources := []struct {
+			files string
+			read  func(string, int) ([]patternSample, error)
+		}{
+			{*wthor, patternSamplesWthor},
+			{*selfplay, patternSamplesSelfPlay},
+		}
+		for _, source := range sources {
+			if source.files == "" {
+				continue
+			}
+			for _, path := range strings.Split(source.files, ",") {
+				s, err := source.read(path, *phases)
+				if err != nil {
+					return err
+				}
+				fmt.Printf("%s: %d positions\n", path, len(s))
+				samples = append(samples, s...)
+			}
+		}
+		if len(samples) == 0 {
+			return errors.New("no training positions, give -wthor or -selfplay files")
+		}
+		pw.train(samples, *epochs, *rate, func(epoch int, loss float64) {
+			if pw.logistic() {
+				fmt.Printf("Epoch %d, log loss %.4f\n", epoch, loss)
+			} else {
+				fmt.Printf("Epoch %d, RMSE %.2f discs\n", epoch, loss)
+			}
+		})
+		return pw.Save(*weights)
+	}
+
+	pw, err := LoadPatternWeights(*weights)
+	if err != nil {
+		return err
+	}
+	game := newGame()
+	if *position != "" {
+		if game, err = ParseBoard(*position); err != nil {
+			return err
+		}
+	}
+	if game.length != patternBoardSize {
+		return fmt.Errorf("patterns are for %dx%d boards", patternBoardSize, patternBoardSize)
+	}
+	game.Show()
+	value, _ := pw.LeafValue(game)
+	if pw.logistic() {
+		fmt.Printf("Score: %+.2f log-odds, win probability %.3f, value %+.3f\n", pw.Score(game), 1/(1+math.Exp(-pw.Score(game))), value)
+	} else {
+		fmt.Printf("Score: %+.1f discs, value %+.3f\n", pw.Score(game), value)
+	}
+	return nil
+}
@@ -0,0 +1,90 @@
+package main
+
+import (
+	"math"
+	"path/filepath"
+	"testing"
+)
+
+func patternTestSamples(t *testing.T) (Board, Board, []patternSample) {
+	// Positions won and lost by the side to move, by 20 and 10 discs
+	won, err := StartOptions{Moves: "F5D6C3D3C4"}.Board()
+	if err != nil {
+		t.Fatal(err)
+	}
+	lost, err := StartOptions{Moves: "F5F6E6F4"}.Board()
+	if err != nil {
+		t.Fatal(err)
+	}
+	pw := PatternWeights{Phases: 1}
+	samples := []patternSample{}
+	for k := 0; k < 50; k++ {
+		samples = append(samples,
+			patternSample{pw.phase(won), patternIndexes(won), 1, 20},
+			patternSample{pw.phase(lost), patternIndexes(lost), 0, -10})
+	}
+	return won, lost, samples
+}
+
+func TestPatternTrainResult(t *testing.T) {
+	// Logistic regression on the result of the game
+	won, lost, samples := patternTestSamples(t)
+	pw := newPatternWeights(1, patternTargetResult)
+	losses := []float64{}
+	pw.train(samples, 20, 0.01, func(epoch int, loss float64) {
+		losses = append(losses, loss)
+	})
+	for k := 1; k < len(losses); k++ {
+		if losses[k] > losses[k-1] {
+			t.Errorf("log loss went up from %f to %f in epoch %d", losses[k-1], losses[k], k+1)
+		}
+	}
+	if p := 1 / (1 + math.Exp(-pw.Score(won))); p < 0.9 {
+		t.Errorf("won position has win probability %f", p)
+	}
+	if p := 1 / (1 + math.Exp(-pw.Score(lost))); p > 0.1 {
+		t.Errorf("lost position has win probability %f", p)
+	}
+	if v, _ := pw.LeafValue(won); math.Abs(v-(2/(1+math.Exp(-pw.Score(won)))-1)) > 1e-9 {
+		t.Errorf("value %f is not the win probability mapped to [-1, 1]", v)
+	}
+}
+
+func TestPatternTrainDiscs(t *testing.T) {
+	// Least squares on the final disc difference
+	won, lost, samples := patternTestSamples(t)
+	pw := newPatternWeights(1, patternTargetDiscs)
+	rmse := 0.0
+	pw.train(samples, 20, 0.002, func(epoch int, loss float64) {
+		rmse = loss
+	})
+	if math.Abs(pw.Score(won)-20) > 1 || math.Abs(pw.Score(lost)+10) > 1 || rmse > 1 {
+		t.Errorf("scores %.2f and %.2f with RMSE %.2f, want 20 and -10", pw.Score(won), pw.Score(lost), rmse)
+	}
+}
+
+func TestPatternWeightsTarget(t *testing.T) {
+	// Weights files without a target hold disc differences, unknown targets are rejected
+	dir := t.TempDir()
+	pw := newPatternWeights(2, "")
+	path := filepath.Join(dir, "old.json")
+	if err := pw.Save(path); err != nil {
+		t.Fatal(err)
+	}
+	loaded, err := LoadPatternWeights(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if loaded.logistic() || loaded.value(patternValueScale) != math.Tanh(1) {
+		t.Errorf("weights without a target not read as disc differences")
+	}
+
+	pw.Target = "margin"
+	path = filepath.Join(dir, "bad.json")
+	if err := pw.Save(path); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := LoadPatternWeights(path); err == nil {
+		t.Errorf("unknown target accepted")
+	}
+}
@@ -0,0 +1,123 @@
+// Perft: counting the leaves of the game tree to a fixed depth
+// A hard check of the move generation, the flipping and the pass logic
+// against known counts, as used for chess and Othello move generators
+// A pass is a move of its own that takes a ply
+// and a finished game is a single leaf whatever the depth
+
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"time"
+)
+
+// Perft of the standard 8x8 start position, by depth from 1
+// The shortest games take 9 plies, so these do not depend on
+// how passes and finished games are counted
+var perftStart = []int{4, 12, 56, 244, 1396, 8200, 55092, 390216, 3005288}
+
+func perft(game *Board, depth int, undo *UndoStack) int {
+	// Number of leaves of the game tree of game at depth
+	// Moves are made and unmade on game, which is left unchanged
+	if depth == 0 || game.winner != 0 {
+		return 1
+	}
+	if len(game.validSpace) == 0 {
+
+		// Only in positions set up with a side to move that cannot play,
+		// Move itself skips the turn of a side that has to pass
+		pass := *game
+		pass.turn = -pass.turn
+		pass.validSpace = pass.getAllValid()
+		if len(pass.validSpace) == 0 {
+			return 1
+		}
+		return perft(&pass, depth-1, undo)
+	}
+	nodes := 0
+	for _, move := range game.validSpace {
+		turn := game.turn
+		game.MakeMove(move, undo)
+		if game.turn == turn && game.winner == 0 && depth > 1 {
+
+			// Move has skipped the turn of the opponent, who has to pass
+			nodes += perft(game, depth-2, undo)
+		} else {
+			nodes += perft(game, depth-1, undo)
+		}
+		game.UnmakeMove(undo)
+	}
+	return nodes
+}
+
+func perftDivide(game Board, depth int) map[Position]int {
+	// Perft at depth split by the first move, to find where two counts differ
+	nodes := map[Position]int{}
+	undo := UndoStack{}
+	for _, move := range game.validSpace {
+		child := game
+		child.MakeMove(move, &undo)
+		if child.turn == game.turn && child.winner == 0 && depth > 1 {
+			nodes[move] = perft(&child, depth-2, &undo)
+		} else {
+			nodes[move] = perft(&child, depth-1, &undo)
+		}
+	}
+	return nodes
+}
+
+func perftCommand(args []string) error {
+	// Count the leaves of the game tree for every depth up to -depth
+	// Example:
+	//     > reversi perft -depth 8
+	//     > reversi perft -depth 5 -divide -position "---------------------------OX------XO--------------------------- X"
+	fs := flag.NewFlagSet("perft", flag.ExitOnError)
+	depth := fs.Int("depth", 6, "maximum depth")
+	divide := fs.Bool("divide", false, "print the count at the maximum depth for every move")
+	start := StartOptions{}
+	start.addFlags(fs)
+	fs.Parse(args)
+
+	if *depth < 1 {
+		return errors.New("depth must be at least 1")
+	}
+	game, err := start.Board()
+	if err != nil {
+		return err
+	}
+	fmt.Println(game.PositionString())
+
+	// Known counts are only checked for the standard start position
+	known := []int{}
+	if game.PositionString() == newGame().PositionString() {
+		known = perftStart
+	}
+	failed := false
+	for d := 1; d <= *depth; d++ {
+		begin := time.Now()
+		nodes := perft(&game, d, &UndoStack{})
+		elapsed := time.Since(begin)
+		check := ""
+		if d <= len(known) {
+			check = "ok"
+			if nodes != known[d-1] {
+				check = fmt.Sprintf("expected %d", known[d-1])
+				failed = true
+			}
+		}
+		fmt.Printf("Depth %2d: %12d leaves in %v (%.0f leaves/s) %s\n",
+			d, nodes, elapsed.Round(time.Millisecond), float64(nodes)/elapsed.Seconds(), check)
+	}
+	if *divide {
+		nodes := perftDivide(game, *depth)
+		for _, move := range game.validSpace {
+			fmt.Printf("%s: %d\n", move.Notation(), nodes[move])
+		}
+	}
+	if failed {
+		return errors.New("perft counts differ from the known counts")
+	}
+	return nil
+}
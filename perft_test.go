@@ -0,0 +1,162 @@
+package main
+
+import (
+	"math/rand"
+	"reflect"
+	"testing"
+)
+
+// Reference move generator working on the squares only
+// It shares no code with Board, so a bug in the incremental
+// neighbours, the flipping or the pass logic shows up as a difference
+func naiveFlips(board *[MaxBoardSize][MaxBoardSize]int8, length int, turn int, i int, j int) []Position {
+	flips := []Position{}
+	if board[i][j] != 0 {
+		return flips
+	}
+	for di := -1; di <= 1; di++ {
+		for dj := -1; dj <= 1; dj++ {
+			if di == 0 && dj == 0 {
+				continue
+			}
+			line := []Position{}
+			y, x := i+di, j+dj
+			for y >= 0 && y < length && x >= 0 && x < length && int(board[y][x]) == -turn {
+				line = append(line, Position{y, x})
+				y, x = y+di, x+dj
+			}
+			if len(line) > 0 && y >= 0 && y < length && x >= 0 && x < length && int(board[y][x]) == turn {
+				flips = append(flips, line...)
+			}
+		}
+	}
+	return flips
+}
+
+func naivePerft(board [MaxBoardSize][MaxBoardSize]int8, length int, turn int, depth int) int {
+	if depth == 0 {
+		return 1
+	}
+	nodes := 0
+	moves := 0
+	for i := 0; i < length; i++ {
+		for j := 0; j < length; j++ {
+			flips := naiveFlips(&board, length, turn, i, j)
+			if len(flips) == 0 {
+				continue
+			}
+			moves++
+			child := board
+			child[i][j] = int8(turn)
+			for _, f := range flips {
+				child[f.i][f.j] = int8(turn)
+			}
+			nodes += naivePerft(child, length, -turn, depth-1)
+		}
+	}
+	if moves > 0 {
+		return nodes
+	}
+
+	// Pass, or a finished game if neither side can move
+	for i := 0; i < length; i++ {
+		for j := 0; j < length; j++ {
+			if len(naiveFlips(&board, length, -turn, i, j)) > 0 {
+				return naivePerft(board, length, -turn, depth-1)
+			}
+		}
+	}
+	return 1
+}
+
+func TestPerftStart(t *testing.T) {
+	game := newGame()
+	for d, want := range perftStart {
+		if d+1 > 8 || testing.Short() && d+1 > 6 {
+			break
+		}
+		if got := perft(&game, d+1, &UndoStack{}); got != want {
+			t.Errorf("perft(%d) = %d, want %d", d+1, got, want)
+		}
+	}
+}
+
+var perftPositions = []struct {
+	name     string
+	position string
+	depth    int
+	nodes    int
+}{
+	{"passes", "XXXXXXXXX-XXXXXOX-XOXOXXXXXOXXX-XXXOOOXO--XXXXX---XXXXOO---OOOOO O", 6, 5643},
+	{"endgame", "XXX--X--XX----XXXXOOOOOXXOXOOXXXXOXXOXOXXOXOXOOXOOOOOOXXXXX-OOX- X", 6, 8428},
+	{"edges", "-OOOOOOXO------OO--XO--OO--OX--OO------OO------OO------OXOOOOOO- X", 4, 638},
+	{"full board", "XXXXXXXXXXXXXXXXXXXXXXXXXXXXXXXXOOOOOOOOOOOOOOOOOOOOOOOOOOOOOOOO X", 3, 1},
+	{"one empty", "XXXXXXXXXXXXXXXXXXXXXXXXXXXXXXXXOOOOOOOOOOOOOOOOOOOOOOOOOOOOOOO- X", 3, 1},
+	{"must pass", "XXXXXXXXXXXXXXXXXXXXXXXXXXXXXXXXOOOOOOOOOOOOOOOOOOOOOOOOOOOOOO-- O", 3, 2},
+	{"blocked", "--------------------#-----------OX-------XO-------#------------- X", 5, 93},
+	{"6x6", "--------------OX----XO-------------- X", 6, 7604},
+}
+
+func TestPerftPositions(t *testing.T) {
+	for _, tc := range perftPositions {
+		game, err := ParseBoard(tc.position)
+		if err != nil {
+			t.Fatalf("%s: %v", tc.name, err)
+		}
+		if got := perft(&game, tc.depth, &UndoStack{}); got != tc.nodes {
+			t.Errorf("%s: perft(%d) = %d, want %d", tc.name, tc.depth, got, tc.nodes)
+		}
+	}
+}
+
+func TestPerftReference(t *testing.T) {
+	// Compare with the reference generator on the test positions
+	// and on positions from random games
+	positions := []Board{}
+	for _, tc := range perftPositions {
+		game, _ := ParseBoard(tc.position)
+		positions = append(positions, game)
+	}
+	r := rand.New(rand.NewSource(1))
+	for g := 0; g < 20; g++ {
+		game := newGame()
+		for ply := r.Intn(50); ply > 0 && game.winner == 0; ply-- {
+			game.Move(game.validSpace[r.Intn(len(game.validSpace))])
+		}
+		positions = append(positions, game)
+	}
+	for _, game := range positions {
+		for depth := 1; depth <= 3; depth++ {
+			got := perft(&game, depth, &UndoStack{})
+			want := naivePerft(game.board, game.length, game.turn, depth)
+			if got != want {
+				t.Errorf("%s: perft(%d) = %d, reference %d", game.PositionString(), depth, got, want)
+			}
+		}
+	}
+}
+
+func TestPerftUnchanged(t *testing.T) {
+	// Making and unmaking all moves leaves the board as it was
+	game, _ := ParseBoard(perftPositions[0].position)
+	before := game.Clone()
+	undo := UndoStack{}
+	perft(&game, 4, &undo)
+	if !reflect.DeepEqual(game, before) {
+		t.Errorf("board changed by perft")
+	}
+	if len(undo) != 0 {
+		t.Errorf("%d moves left on the undo stack", len(undo))
+	}
+}
+
+func TestPerftDivide(t *testing.T) {
+	game := newGame()
+	total := 0
+	for _, nodes := range perftDivide(game, 4) {
+		total += nodes
+	}
+	if total != perftStart[3] {
+		t.Errorf("divided perft(4) = %d, want %d", total, perftStart[3])
+	}
+}
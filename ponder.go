@@ -0,0 +1,76 @@
+// Background search on the opponent's time ("pondering")
+// While the human is thinking the agent keeps searching the current position
+// Once the human has moved, the subtree below that move is kept
+// and the agent's search continues from it
+
+package main
+
+import (
+	"math"
+	"time"
+)
+
+const maxPonderTime = 5 * time.Minute // Longest search while waiting for a move
+
+// Background searches running at the same time on the server
+// While all are in use, games skip pondering until one is free
+const maxPonderSearches = 4
+
+var ponderSlots = make(chan struct{}, maxPonderSearches)
+
+type ponderer struct {
+
+	// Struct to hold a search running in the background
+	// The tree below root must not be read until Stop has returned
+
+	root *Node
+	stop chan struct{} // Closed to stop the search
+	done chan struct{} // Closed once the search has stopped
+}
+
+func startPonder(game Board, nSims int, limit time.Duration, p Params) *ponderer {
+	// Start searching game in the background for at most limit
+	// Returns nil if maxPonderSearches searches are already running
+	select {
+	case ponderSlots <- struct{}{}:
+	default:
+		return nil
+	}
+	pd := &ponderer{
+		root: &Node{
+			state: game.compact(),
+			depth: 0,
+		},
+		stop: make(chan struct{}),
+		done: make(chan struct{}),
+	}
+	go func() {
+		defer close(pd.done)
+		defer func() { <-ponderSlots }()
+		searchTree(pd.root, nSims, math.MaxInt, time.Now().Add(limit), pd.stop, p)
+	}()
+	return pd
+}
+
+func (pd *ponderer) Stop() {
+	// Stop the search and wait for it to finish
+	select {
+	case <-pd.stop:
+	default:
+		close(pd.stop)
+	}
+	<-pd.done
+}
+
+func (pd *ponderer) subtree(move Position) *Node {
+	// Detach the subtree after move from the stopped search
+	// Returns nil if move was never expanded
+	// The rest of the tree is returned to the pool of the tree
+	for _, child := range pd.root.children {
+		if child.position == move {
+			pd.root.pool.detach(pd.root, child)
+			return child
+		}
+	}
+	return nil
+}
@@ -0,0 +1,206 @@
+// Single-line position format for boards
+// 64 characters for the squares, row by row from A1 to H8,
+// followed by the side to move, as used by common Othello tools
+// Other board sizes have size x size characters for the squares
+// Blocked squares are written as #
+// Example (start position, black to move):
+//     ---------------------------OX------XO--------------------------- X
+
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"math"
+	"strings"
+	"time"
+)
+
+func (X Board) PositionString() string {
+	// Format the Board as a single-line position string
+	// Black pieces are X, white pieces are O and empty spaces are -
+	s := strings.Builder{}
+	for i := 0; i < X.length; i++ {
+		for j := 0; j < X.length; j++ {
+			switch X.board[i][j] {
+			case 1:
+				s.WriteByte('X')
+			case -1:
+				s.WriteByte('O')
+			case blockedSquare:
+				s.WriteByte('#')
+			default:
+				s.WriteByte('-')
+			}
+		}
+	}
+	if X.turn == -1 {
+		s.WriteString(" O")
+	} else {
+		s.WriteString(" X")
+	}
+	return s.String()
+}
+
+func ParseBoard(position string) (Board, error) {
+	// Parse a single-line position string into a Board
+	// Accepts X, x or * for black, O or o for white, - or . for empty, # for blocked
+	// Side to move is X or O, optionally separated by whitespace
+	// The board size is given by the number of squares
+	s := strings.Join(strings.Fields(position), "")
+	size := 0
+	for k := MinBoardSize; k <= MaxBoardSize; k += 2 {
+		if len(s) == k*k+1 {
+			size = k
+		}
+	}
+	if size == 0 {
+		return Board{}, fmt.Errorf("position must have size x size squares and a side to move, got %q", position)
+	}
+	squares := size * size
+	Grid := [MaxBoardSize][MaxBoardSize]int8{}
+	for k := 0; k < squares; k++ {
+		switch s[k] {
+		case 'X', 'x', '*':
+			Grid[k/size][k%size] = 1
+		case 'O', 'o':
+			Grid[k/size][k%size] = -1
+		case '#':
+			Grid[k/size][k%size] = blockedSquare
+		case '-', '.':
+		default:
+			return Board{}, fmt.Errorf("invalid square %q at %d in position", s[k], k)
+		}
+	}
+	turn := 0
+	switch s[squares] {
+	case 'X', 'x', '*':
+		turn = 1
+	case 'O', 'o':
+		turn = -1
+	default:
+		return Board{}, fmt.Errorf("invalid side to move %q in position", s[squares])
+	}
+	B := Board{
+		length: size,
+		board:  Grid,
+		turn:   turn,
+	}
+	B.Setup()
+
+	return B, nil
+}
+
+func LoadGame(state GameState) (Board, error) {
+	// Setup the board for a game state posted to the API
+	// The position string takes precedence over the filled lists if given
+	// The rule variant is checked up front as SetGame ignores unknown variants
+	// and the final move policy so a bad request fails before searching
+	// Squares outside the board or in more than one of the filled lists,
+	// an unknown side to move and positions where the side to move has
+	// no valid move are rejected as well
+	game := Board{}
+	if err := game.setVariant(state.Variant); err != nil {
+		return game, err
+	}
+	misere := game.misere
+	if err := validFinalPolicy(state.Policy); err != nil {
+		return game, err
+	}
+	if state.Position != "" {
+		var err error
+		if game, err = ParseBoard(state.Position); err != nil {
+			return game, err
+		}
+		game.misere = misere
+	} else {
+		size := state.BoardSize
+		if size == 0 {
+			size = 8
+		}
+		if err := validBoardSize(size); err != nil {
+			return Board{}, err
+		}
+		if state.Turn != 1 && state.Turn != -1 {
+			return Board{}, fmt.Errorf("turn must be 1 (black) or -1 (white), got %d", state.Turn)
+		}
+		lists := []string{"blackFilled", "whiteFilled", "blocked"}
+		listOf := map[[2]int]int{}
+		for k, squares := range [][][2]int{state.BlackFilled, state.WhiteFilled, state.Blocked} {
+			for _, s := range squares {
+				if s[0] < 0 || s[0] >= size || s[1] < 0 || s[1] >= size {
+					return Board{}, fmt.Errorf("square %v is outside the %dx%d board", s, size, size)
+				}
+				if l, ok := listOf[s]; ok && l != k {
+					return Board{}, fmt.Errorf("square %v is in both %s and %s", s, lists[l], lists[k])
+				}
+				listOf[s] = k
+			}
+		}
+		game = SetGame(state)
+	}
+	if len(game.validSpace) == 0 {
+		return game, errors.New("the side to move has no valid move")
+	}
+	return game, nil
+}
+
+func searchCommand(args []string) error {
+	// Search for the agent's move in a position given on the command line
+	// Example:
+	//     > reversi search -position "---------------------------OX------XO--------------------------- X"
+	fs := flag.NewFlagSet("search", flag.ExitOnError)
+	position := fs.String("position", "", "position string to search (default start position)")
+	start := StartOptions{}
+	start.addFlags(fs)
+	nSims := fs.Int("sims", 20, "number of rollouts per leaf")
+	maxIter := fs.Int("iter", 300, "number of search iterations")
+	clock := fs.Duration("clock", 0, "time left on the clock of the side to move, searches for a time budget instead of -iter")
+	increment := fs.Duration("inc", 0, "increment per move of the clock")
+	p := DefaultParams
+	fs.StringVar(&p.FinalPolicy, "policy", "heuristic", fmt.Sprintf("final move policy, one of %v", finalPolicies))
+	fs.Func("params", "colon separated search parameters as in agent specifications, e.g. \"puct=1.5:prior=heuristic\"", func(s string) error {
+		for _, param := range strings.Split(s, ":") {
+			kv := strings.SplitN(param, "=", 2)
+			if len(kv) != 2 {
+				return fmt.Errorf("invalid parameter %q, expected name=value", param)
+			}
+			if err := p.SetString(kv[0], kv[1]); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	fs.Parse(args)
+
+	if err := validParams(p); err != nil {
+		return err
+	}
+	if *position != "" {
+		start.Position = *position
+	}
+	game, err := start.Board()
+	if err != nil {
+		return err
+	}
+	game.Show()
+	if len(game.validSpace) == 0 {
+		return fmt.Errorf("no valid moves in position")
+	}
+	root := Node{
+		state: game.compact(),
+		depth: 0,
+	}
+	iterations, deadline := *maxIter, time.Time{}
+	if *clock > 0 {
+		budget := allocateTime(*clock, *increment, game.emptyCount())
+		fmt.Println("Time budget:", budget)
+		iterations, deadline = math.MaxInt, time.Now().Add(budget)
+	}
+	decision, policy := searchTree(&root, *nSims, iterations, deadline, nil, p)
+	game.Move(decision)
+	fmt.Println("Move:", decision.Notation(), "by policy", policy)
+	fmt.Println("Position:", game.PositionString())
+	return nil
+}
@@ -0,0 +1,56 @@
+package main
+
+import (
+	"testing"
+)
+
+func TestLoadGameErrors(t *testing.T) {
+	start := newGame().GameState()
+	start.Position = ""
+	bad := map[string]func(s *GameState){
+		"variant":          func(s *GameState) { s.Variant = "giveaway" },
+		"policy":           func(s *GameState) { s.Policy = "best" },
+		"board size":       func(s *GameState) { s.BoardSize = 7 },
+		"turn":             func(s *GameState) { s.Turn = 0 },
+		"square too large": func(s *GameState) { s.BlackFilled = append(s.BlackFilled, [2]int{8, 0}) },
+		"negative square":  func(s *GameState) { s.WhiteFilled = append(s.WhiteFilled, [2]int{0, -1}) },
+		"blocked outside":  func(s *GameState) { s.Blocked = [][2]int{{3, 99}} },
+		"overlapping":      func(s *GameState) { s.Blocked = [][2]int{{3, 3}} },
+		"no valid move":    func(s *GameState) { s.WhiteFilled = [][2]int{} },
+		"position":         func(s *GameState) { s.Position = "XO X" },
+		"position no move": func(s *GameState) { s.Position = "XXXXXXXXXXXXXXXXXXXXXXXXXXXXXXXXOOOOOOOOOOOOOOOOOOOOOOOOOOOOOOOO X" },
+	}
+	for name, change := range bad {
+		state := start
+		state.BlackFilled = append([][2]int{}, start.BlackFilled...)
+		change(&state)
+		if _, err := LoadGame(state); err == nil {
+			t.Errorf("%s: no error", name)
+		}
+	}
+	if _, err := LoadGame(start); err != nil {
+		t.Errorf("start position: %v", err)
+	}
+	misere := GameState{Variant: "misere", Position: "---------------------------OX------XO--------------------------- X"}
+	if game, err := LoadGame(misere); err != nil || !game.misere {
+		t.Errorf("misere position: variant not kept, error %v", err)
+	}
+}
+
+func TestPositionString(t *testing.T) {
+	for _, position := range []string{
+		"---------------------------OX------XO--------------------------- X",
+		"--------------------#-----------OX-------XO-------#------------- O",
+		"--------------OX----XO-------------- X",
+	} {
+		game := mustParseBoard(t, position)
+		if got := game.PositionString(); got != position {
+			t.Errorf("PositionString() = %s, want %s", got, position)
+		}
+	}
+	for _, position := range []string{"", "XO", "---------------------------OX------XO--------------------------- Z", "---------------------------OX------XO---------------------------? X"} {
+		if _, err := ParseBoard(position); err == nil {
+			t.Errorf("ParseBoard(%q): no error", position)
+		}
+	}
+}
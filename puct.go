@@ -0,0 +1,190 @@
+// PUCT selection with prior probabilities of the moves
+// Domain knowledge is given as a prior over the valid moves of a position
+// by a PriorProvider, computed once when a node is expanded
+// Selection then explores moves in proportion to their prior
+// instead of adjusting the UCT score by hand
+
+package main
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+)
+
+type PriorProvider interface {
+	// Prior probabilities of the valid moves of game
+	// in the order of game.validSpace, summing to 1
+	Priors(game Board) []float64
+}
+
+// Prior providers by name, as used by the prior parameter
+var priorProviders = map[string]PriorProvider{
+	"uniform":   uniformPrior{},
+	"heuristic": heuristicPrior{},
+}
+
+func findPrior(name string) (PriorProvider, error) {
+	if name == "" {
+		name = "heuristic"
+	}
+	provider, ok := priorProviders[name]
+	if !ok {
+		names := []string{}
+		for n := range priorProviders {
+			names = append(names, n)
+		}
+		sort.Strings(names)
+		return nil, fmt.Errorf("unknown prior %q, expected one of %v", name, names)
+	}
+	return provider, nil
+}
+
+func priorFor(p Params) (PriorProvider, error) {
+	// Prior provider of the search parameters
+	// The network prior uses the network of p.Network
+	// and the patterns prior the pattern weights of p.Patterns
+	switch p.Prior {
+	case "network":
+	case "patterns":
+		if p.Patterns == "" {
+			return nil, errors.New("the patterns prior needs pattern weights")
+		}
+		pw, err := cachedPatternWeights(p.Patterns)
+		if err != nil {
+			return nil, err
+		}
+		return patternPrior{pw}, nil
+	default:
+		return findPrior(p.Prior)
+	}
+	if p.Network == "" {
+		return nil, errors.New("the network prior needs a network")
+	}
+	net, err := cachedNetwork(p.Network)
+	if err != nil {
+		return nil, err
+	}
+	return networkPrior{net}, nil
+}
+
+func validParams(p Params) error {
+	// Check the search parameters that are given by name
+	if err := validFinalPolicy(p.FinalPolicy); err != nil {
+		return err
+	}
+	_, err := priorFor(p)
+	return err
+}
+
+// Same prior for every move
+type uniformPrior struct{}
+
+func (uniformPrior) Priors(game Board) []float64 {
+	priors := make([]float64, len(game.validSpace))
+	for i := range priors {
+		priors[i] = 1 / float64(len(priors))
+	}
+	return priors
+}
+
+// Logits of the heuristic prior
+const (
+	priorCorner   = 2.0  // Corners
+	priorBad      = -0.5 // Positions adjacent to the corners
+	priorVeryBad  = -1.5 // Positions giving corners away
+	priorMobility = -0.2 // Per valid move left to the opponent
+)
+
+// Prior from the heuristic positions and the mobility left to the opponent
+type heuristicPrior struct{}
+
+func (heuristicPrior) Priors(game Board) []float64 {
+	logits := make([]float64, len(game.validSpace))
+	for i, move := range game.validSpace {
+		position := 0.0
+		switch {
+		case posInSlice(move, corners(game.length)):
+			position = priorCorner
+		case posInSlice(move, badPositions(game.length)):
+			position = priorBad
+		case posInSlice(move, veryBadPositions(game.length)):
+			position = priorVeryBad
+		}
+
+		// In the misere variant giving corners away is good
+		if game.misere {
+			position = -position
+		}
+		child := game
+		child.Move(move)
+		mobility := 0.0
+		if child.turn != game.turn {
+			mobility = float64(len(child.validSpace))
+		}
+		logits[i] = position + priorMobility*mobility
+	}
+	return softmax(logits)
+}
+
+func softmax(logits []float64) []float64 {
+	max := math.Inf(-1)
+	for _, l := range logits {
+		max = math.Max(max, l)
+	}
+	sum := 0.0
+	probs := make([]float64, len(logits))
+	for i, l := range logits {
+		probs[i] = math.Exp(l - max)
+		sum += probs[i]
+	}
+	for i := range probs {
+		probs[i] /= sum
+	}
+	return probs
+}
+
+func (n *Node) childValue(child *Node, p Params) float64 {
+	// Estimated win rate of child for the side to move at n
+	// Unvisited children get the value of n itself
+	// RAVE values are blended in as in selectChild
+	if child.played == 0 {
+		return float64(n.wins) / float64(n.played+1)
+	}
+	value := n.childRate(child)
+	if p.RaveEquivalence > 0 && child.amafPlayed > 0 {
+		amaf := float64(child.amafWins) / float64(child.amafPlayed)
+		if child.state.turn != n.state.turn {
+			amaf = 1 - amaf
+		}
+		beta := math.Sqrt(p.RaveEquivalence / (3*float64(child.played) + p.RaveEquivalence))
+		value += beta * (amaf - value)
+	}
+	return value
+}
+
+func (n *Node) selectPUCT(best string, p Params) *Node {
+	// PUCT selection, always from the perspective of the side to move at n
+	// Tree policy ("max"): value + puct * prior * sqrt(visits of n) / (1 + visits of child)
+	// Final choice ("min"): the best value among visited children
+	// Proven nodes are not sampled any more, as in selectChild
+	choice := n.children[0]
+	bestScore := math.Inf(-1)
+	for _, child := range n.children {
+		score := n.childValue(child, p)
+		if best == "max" {
+			if child.proven != 0 {
+				continue
+			}
+			score += p.PUCT * child.prior * math.Sqrt(float64(n.played)) / float64(1+child.played)
+		} else if child.played == 0 {
+			continue
+		}
+		if score > bestScore {
+			bestScore = score
+			choice = child
+		}
+	}
+	return choice
+}
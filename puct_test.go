@@ -0,0 +1,71 @@
+package main
+
+import (
+	"math"
+	"testing"
+)
+
+// Prior putting most of the probability on the move at index peak
+type peakedPrior struct{ peak int }
+
+func (pp peakedPrior) Priors(game Board) []float64 {
+	priors := make([]float64, len(game.validSpace))
+	for i := range priors {
+		priors[i] = 0.1 / float64(len(priors)-1)
+	}
+	priors[pp.peak] = 0.9
+	return priors
+}
+
+func TestPUCTHighestPrior(t *testing.T) {
+	// Without visits of the children, PUCT selects the move with the highest prior
+	game := newGame()
+	if err := game.playMoves([]Position{{4, 5}, {5, 3}, {2, 2}}); err != nil {
+		t.Fatal(err)
+	}
+	p := DefaultParams
+	p.PUCT = 1.5
+	for peak := range game.validSpace {
+		root := Node{state: game.compact(), played: 20, wins: 10}
+		root.expandNode(peakedPrior{peak}, &nodePool{})
+		if len(root.children) != len(game.validSpace) {
+			t.Fatalf("%d children for %d moves", len(root.children), len(game.validSpace))
+		}
+		if choice := root.selectChild(root.played, "max", p); choice.position != game.validSpace[peak] {
+			t.Errorf("selected %s, prior is highest for %s", choice.position.Notation(), game.validSpace[peak].Notation())
+		}
+	}
+}
+
+func TestPriors(t *testing.T) {
+	// Priors sum to 1, and symmetric moves add theirs to the one child kept
+	game := newGame()
+	root := Node{state: game.compact()}
+	root.expandNode(uniformPrior{}, &nodePool{})
+	if len(root.children) != 1 || math.Abs(root.children[0].prior-1) > 1e-9 {
+		t.Errorf("start position: %d children, first with prior %f, want 1 with 1", len(root.children), root.children[0].prior)
+	}
+
+	// X to move can take the corner A1 besides the moves in the centre
+	corner := mustParseBoard(t, "-OX-----"+"--------"+"--------"+"---OX---"+"---XO---"+"--------"+"--------"+"-------- X")
+	if len(corner.validSpace) < 2 {
+		t.Fatalf("valid moves %v", corner.validSpace)
+	}
+	priors := heuristicPrior{}.Priors(corner)
+	sum := 0.0
+	for _, prior := range priors {
+		sum += prior
+	}
+	if math.Abs(sum-1) > 1e-9 {
+		t.Errorf("heuristic priors add up to %f", sum)
+	}
+	k := 0
+	for i := range priors {
+		if priors[i] > priors[k] {
+			k = i
+		}
+	}
+	if corner.validSpace[k] != (Position{0, 0}) {
+		t.Errorf("highest prior for %s, want A1", corner.validSpace[k].Notation())
+	}
+}
@@ -0,0 +1,84 @@
+// Rapid Action Value Estimation (RAVE)
+// Reversi moves depend strongly on the square they are played on,
+// so a square that wins when played anywhere later in a rollout
+// is likely a good move now as well (all-moves-as-first, AMAF)
+// The AMAF values give estimates for nodes with few visits
+// and are blended with the UCT values in selectChild
+
+package main
+
+type amafMove struct {
+	colour   int
+	position Position
+}
+
+type amafCounts struct {
+
+	// Struct to hold the squares played in a batch of rollouts
+	// Colours and winners are indexed by colourIndex
+
+	blackWins int // Rollouts won by black
+	whiteWins int // Rollouts won by white
+
+	played [2][MaxBoardSize][MaxBoardSize]int    // Rollouts in which the colour played the square
+	won    [2][2][MaxBoardSize][MaxBoardSize]int // Of those, rollouts won by each colour
+
+	moves []amafMove // Moves of the rollout in progress
+}
+
+func (a *amafCounts) reset() {
+	moves := a.moves[:0]
+	*a = amafCounts{moves: moves}
+}
+
+func (a *amafCounts) record(colour int, move Position) {
+	a.moves = append(a.moves, amafMove{colour, move})
+}
+
+func (a *amafCounts) finish(winner int) {
+	// Count the moves of a finished rollout
+	switch winner {
+	case 1:
+		a.blackWins++
+	case -1:
+		a.whiteWins++
+	}
+	for _, m := range a.moves {
+		c := colourIndex(m.colour)
+		a.played[c][m.position.i][m.position.j]++
+		if winner == 1 || winner == -1 {
+			a.won[c][colourIndex(winner)][m.position.i][m.position.j]++
+		}
+	}
+	a.moves = a.moves[:0]
+}
+
+func backPropAMAF(n *Node, amaf *amafCounts, nSims int) {
+	// Update the AMAF statistics of the siblings along the path from n to the root
+	// A sibling counts a rollout if the parent's side to move played its square
+	// anywhere below the parent, in the tree or in the rollout
+	// Wins are counted for the side to move at the sibling, as in backProp
+	if amaf == nil {
+		return
+	}
+	var inTree [2][MaxBoardSize][MaxBoardSize]bool
+	for child := n; child.parent != nil; child = child.parent {
+		parent := child.parent
+		c := colourIndex(parent.state.turn)
+		inTree[c][child.position.i][child.position.j] = true
+		for _, sibling := range parent.children {
+			m := sibling.position
+			played := amaf.played[c][m.i][m.j]
+			wins := amaf.won[c][colourIndex(sibling.state.turn)][m.i][m.j]
+			if inTree[c][m.i][m.j] {
+				played = nSims
+				wins = amaf.whiteWins
+				if sibling.state.turn == 1 {
+					wins = amaf.blackWins
+				}
+			}
+			sibling.amafPlayed += played
+			sibling.amafWins += wins
+		}
+	}
+}
@@ -9,6 +9,7 @@ import (
 	"math/rand"
 	"os"
 	"strconv"
+	"strings"
 	"time"
 )
 
@@ -34,27 +35,77 @@ var Directions = []Position{
 	Position{-1, -1},
 }
 
+// Supported board sizes, any even size in between
+const (
+	MinBoardSize = 4
+	MaxBoardSize = 16
+)
+
+// Value of a blocked square on the board
+// No piece can be placed on it and it interrupts flipping lines
+const blockedSquare = 2
+
+// Positions used by the heuristics for each board size
+type positionSet struct {
+	corners []Position
+	bad     []Position
+	veryBad []Position
+}
+
+var heuristicPositions = map[int]positionSet{}
+
+func init() {
+	// rand is deterministic. Seed it once here, seeding it again
+	// before every random move made rollouts several times slower
+	// Benchmarks seed it with a fixed value instead, see bench.go
+	rand.Seed(time.Now().UTC().UnixNano())
+
+	// Derive the heuristic positions for every supported board size
+	for size := MinBoardSize; size <= MaxBoardSize; size += 2 {
+		last := size - 1
+		heuristicPositions[size] = positionSet{
+			corners: []Position{
+				{0, 0}, {0, last}, {last, 0}, {last, last},
+			},
+			bad: []Position{
+				{0, 1}, {1, 0},
+				{last - 1, 0}, {last, 1},
+				{last, last - 1}, {last - 1, last},
+				{0, last - 1}, {1, last},
+			},
+			veryBad: []Position{
+				{1, 1}, {1, last - 1}, {last - 1, 1}, {last - 1, last - 1},
+			},
+		}
+	}
+}
+
 // Corner pieces in reversi/othello are considered high in value
 // For heuristics to supplement UCT to select child node
-var corners = []Position{
-	{0, 0}, {0, 7}, {7, 0}, {7, 7},
+func corners(length int) []Position {
+	return heuristicPositions[length].corners
 }
 
 // Generally considered bad positions
 // These are positions adjacent to the corners
 // For heuristics to supplement UCT to select child node
-var badPositions = []Position{
-	{0, 1}, {1, 0},
-	{6, 0}, {7, 1},
-	{7, 6}, {6, 7},
-	{0, 6}, {1, 7},
+func badPositions(length int) []Position {
+	return heuristicPositions[length].bad
 }
 
 // Generally considered very bad positions
 // These are positions that give corners away
 // For heuristics to supplement UCT to select child node
-var veryBadPositions = []Position{
-	{1, 1}, {1, 6}, {6, 1}, {6, 6},
+func veryBadPositions(length int) []Position {
+	return heuristicPositions[length].veryBad
+}
+
+func validBoardSize(size int) error {
+	// Check if a board size is supported
+	if size < MinBoardSize || size > MaxBoardSize || size%2 != 0 {
+		return fmt.Errorf("board size must be an even number from %d to %d, got %d", MinBoardSize, MaxBoardSize, size)
+	}
+	return nil
 }
 
 type GameState struct {
@@ -65,6 +116,11 @@ type GameState struct {
 	BlackFilled [][2]int `json:"blackFilled"` // Currently filled black pieces on board
 	WhiteFilled [][2]int `json:"whiteFilled"` // Currently filled white pieces on board
 	Turn        int      `json:"turn"`        // Agent's turn to play as (1 for black, -1 for white)
+	BoardSize   int      `json:"boardSize"`   // Length of the board, 8 if not given
+	Blocked     [][2]int `json:"blocked"`     // Blocked squares no piece can be placed on
+	Variant     string   `json:"variant"`     // Rule variant, "standard" (default) or "misere"
+	Position    string   `json:"position"`    // Alternative to the fields above as a single-line position string
+	Policy      string   `json:"policy"`      // Final move policy of the agent, heuristic if not given
 }
 
 type DecisionResponse struct {
@@ -73,10 +129,11 @@ type DecisionResponse struct {
 	// in response to the gamestate posted by user to endpoint
 
 	Move       [2]int `json:"move"`       // Decision of agent for Position to place piece
-	Colour     int    `json:"colour`      // Colour of the piece placed by agent
+	Colour     int    `json:"colour"`     // Colour of the piece placed by agent
 	Turn       int    `json:"turn"`       // Whose turn it is after move is made
 	BlackScore int    `json:"blackScore"` // Black's Score after Move is made
 	WhiteScore int    `json:"whiteScore"` // White's Score after Move is made
+	Policy     string `json:"policy"`     // Policy that selected the move, see finalPolicies
 }
 
 func (position Position) PrintPrettifyNotation() strPosition {
@@ -84,11 +141,57 @@ func (position Position) PrintPrettifyNotation() strPosition {
 	// to standard Othello notation
 	// Example:
 	//     (0,0) -> "A1", Top left corner
-	//     (0,7) -> "H1", Top right corner
-	alphabet := []string{"A", "B", "C", "D", "E", "F", "G", "H"}
+	//     (0,7) -> "H1", Top right corner of an 8x8 board
+	//     (9,9) -> "J10", Bottom right corner of a 10x10 board
+	alphabet := []string{"A", "B", "C", "D", "E", "F", "G", "H", "I", "J", "K", "L", "M", "N", "O", "P"}
 	return strPosition{alphabet[position.j], strconv.Itoa(position.i + 1)}
 }
 
+func (position Position) Notation() string {
+	// Standard Othello notation of a Position as a single string
+	// Example:
+	//     (2,3) -> "D3"
+	s := position.PrintPrettifyNotation()
+	return s.i + s.j
+}
+
+func parseNotation(s string) (Position, error) {
+	// Converts standard Othello notation (e.g. "D3" or "d3")
+	// back to a Position in (row, column) notation
+	if len(s) < 2 {
+		return Position{}, fmt.Errorf("invalid move notation %q", s)
+	}
+	col := strings.ToUpper(s[:1])[0]
+	row, err := strconv.Atoi(s[1:])
+	if err != nil || col < 'A' || col >= 'A'+MaxBoardSize || row < 1 || row > MaxBoardSize {
+		return Position{}, fmt.Errorf("invalid move notation %q", s)
+	}
+	return Position{row - 1, int(col - 'A')}, nil
+}
+
+func parseMoves(s string) ([]Position, error) {
+	// Converts a move sequence in standard Othello notation to Positions
+	// Moves may be separated by spaces or written together
+	// Each move is a column letter followed by a row number
+	// Example:
+	//     "F5D6C3", "f5 d6 c3" or "F5J10" on a 10x10 board
+	s = strings.Join(strings.Fields(s), "")
+	moves := []Position{}
+	for len(s) > 0 {
+		end := 1
+		for end < len(s) && s[end] >= '0' && s[end] <= '9' {
+			end++
+		}
+		move, err := parseNotation(s[:end])
+		if err != nil {
+			return nil, err
+		}
+		moves = append(moves, move)
+		s = s[end:]
+	}
+	return moves, nil
+}
+
 func posInSlice(a Position, list []Position) bool {
 	// Check if a Position is in a Slice
 	for _, b := range list {
@@ -100,22 +203,24 @@ func posInSlice(a Position, list []Position) bool {
 }
 
 // The Reversi/Othello board
+// Copies share the backing arrays of the slices, see Clone
 type Board struct {
-	length     int        // Max length of board (i.e., 8 for standard board size)
-	board      [8][8]int  // State of board
-	filled     []Position // Slice of all spaces filled up by a piece
-	empty      []Position // Slice of all spaces that remain empty
-	neighbours []Position // Slice of all empty neighbours of pieces
-	validSpace []Position // Slice of all valid moves for the player turn
-	blackScore int        // Total number of Black pieces on board(1)
-	whiteScore int        // Total number of White pieces on board (-1)
-	winner     int        // Winner of game - Black (1), White (-1), Draw (99), Undetermined (0). Undetermined is default
-	turn       int        // Whose turn is it (1 for Black, -1 for White)
+	length     int                              // Max length of board (i.e., 8 for standard board size)
+	board      [MaxBoardSize][MaxBoardSize]int8 // State of board, only the top left length x length is used (int8 keeps copies small)
+	filled     []Position                       // Slice of all spaces filled up by a piece
+	empty      []Position                       // Slice of all spaces that remain empty
+	neighbours []Position                       // Slice of all empty neighbours of pieces
+	validSpace []Position                       // Slice of all valid moves for the player turn
+	blackScore int                              // Total number of Black pieces on board(1)
+	whiteScore int                              // Total number of White pieces on board (-1)
+	winner     int                              // Winner of game - Black (1), White (-1), Draw (99), Undetermined (0). Undetermined is default
+	turn       int                              // Whose turn is it (1 for Black, -1 for White)
+	misere     bool                             // Anti-reversi variant, the player with fewer pieces wins
 }
 
 func (X Board) Show() {
 	// Display board in terminal
-	dim := len(X.board) - 1
+	dim := X.length - 1
 	for i := 0; i <= dim; i++ {
 		for j := 0; j <= dim; j++ {
 			showPiece := "   "
@@ -125,21 +230,26 @@ func (X Board) Show() {
 			if X.board[i][j] == -1 {
 				showPiece = " O "
 			}
+			if X.board[i][j] == blockedSquare {
+				showPiece = " # "
+			}
 			fmt.Printf("%s", showPiece)
 		}
-		fmt.Println("\n")
+		fmt.Print("\n\n")
 	}
 }
 
-func (X Board) inRange(space Position) bool {
+func (X *Board) inRange(space Position) bool {
 	// Check if Position can exist in the board
 	// Example:
 	//     Position{12, 8} is out of range and invalid in an 8x8 board
-	// Return false if Position is out of range
+	// Blocked squares do not exist in the board either
+	// Return false if Position is out of range or blocked
 	if space.i < X.length &&
 		space.j < X.length &&
 		space.i >= 0 &&
-		space.j >= 0 {
+		space.j >= 0 &&
+		X.board[space.i][space.j] != blockedSquare {
 		return true
 	} else {
 		return false
@@ -197,6 +307,20 @@ func (X *Board) getScores() (int, int) {
 	return blackScore, whiteScore
 }
 
+func (X *Board) emptyCount() int {
+	// Count all empty spaces
+	// X.empty is only filled in by Setup and is not updated by Move
+	count := 0
+	for i := 0; i < X.length; i++ {
+		for j := 0; j < X.length; j++ {
+			if X.board[i][j] == 0 {
+				count += 1
+			}
+		}
+	}
+	return count
+}
+
 func (X *Board) Setup() {
 	// Initialize parameters of a Board
 	// Called each time a new board is created
@@ -212,7 +336,7 @@ func (X *Board) Setup() {
 		for j := 0; j < X.length; j++ {
 			if X.board[i][j] == 0 {
 				X.empty = append(X.empty, Position{i, j})
-			} else {
+			} else if X.board[i][j] != blockedSquare {
 				X.filled = append(X.filled, Position{i, j})
 			}
 		}
@@ -262,7 +386,7 @@ func (X *Board) checkValidDir(iDir int, jDir int, space Position) bool {
 			// If the current space is same colour - Invalid
 			// This condition only applies when current space is the
 			// nearest (first) neighbour of the original starting Position
-			case X.board[space.i][space.j] == X.turn && firstShift == true:
+			case int(X.board[space.i][space.j]) == X.turn && firstShift == true:
 				break loop
 
 			// If the current space is same colour, but shifted before,
@@ -270,7 +394,7 @@ func (X *Board) checkValidDir(iDir int, jDir int, space Position) bool {
 			// End the check
 			// This direction is considered valid
 			// All pieces in this direction up to this piece should be flipped
-			case X.board[space.i][space.j] == X.turn && firstShift == false:
+			case int(X.board[space.i][space.j]) == X.turn && firstShift == false:
 				valid = true
 				break loop
 
@@ -278,7 +402,7 @@ func (X *Board) checkValidDir(iDir int, jDir int, space Position) bool {
 			// continue and shift to next space
 			// The next space will not be the nearest neighbour anymore,
 			// So firstShift is set to false
-			case X.board[space.i][space.j] == -X.turn:
+			case int(X.board[space.i][space.j]) == -X.turn:
 				firstShift = false
 				continue
 			}
@@ -325,7 +449,7 @@ func (X *Board) showAllValid() {
 		}
 	}
 	for i := 0; i < X.length; i++ {
-		fmt.Println(Y[i])
+		fmt.Println(Y[i][:X.length])
 	}
 }
 
@@ -336,100 +460,142 @@ func (X *Board) Move(piece Position) {
 	if posInSlice(piece, X.validSpace) == false {
 		fmt.Println("This is an invalid move")
 	} else {
-		flippedCount := 0
-		for _, dir := range Directions {
-			if X.checkValidDir(dir.i, dir.j, piece) {
-
-				// Flip all opposing pieces in the direction until same colour is met.
-				// Given that the direction is valid
-				nextPiece := Position{piece.i + dir.i, piece.j + dir.j}
-			loop:
-				for {
-
-					// Move space (position) in direction of iDir and jDir
-					// If next space is the same colour, flipping stops
-					if X.board[nextPiece.i][nextPiece.j] == X.turn {
-						break loop
-					} else {
-
-						// Flip the next piece and move one space in the given direction
-						X.board[nextPiece.i][nextPiece.j] = X.turn
-						flippedCount += 1
-						nextPiece.i += dir.i
-						nextPiece.j += dir.j
+		X.play(piece, nil)
+	}
+}
+
+func (X *Board) play(piece Position, flipped *[]Position) {
+	// Play the valid move piece as Move does
+	// Appends the flipped pieces to flipped unless it is nil
+	flippedCount := 0
+	for _, dir := range Directions {
+		if X.checkValidDir(dir.i, dir.j, piece) {
+
+			// Flip all opposing pieces in the direction until same colour is met.
+			// Given that the direction is valid
+			nextPiece := Position{piece.i + dir.i, piece.j + dir.j}
+		loop:
+			for {
+
+				// Move space (position) in direction of iDir and jDir
+				// If next space is the same colour, flipping stops
+				if int(X.board[nextPiece.i][nextPiece.j]) == X.turn {
+					break loop
+				} else {
+
+					// Flip the next piece and move one space in the given direction
+					X.board[nextPiece.i][nextPiece.j] = int8(X.turn)
+					flippedCount += 1
+					if flipped != nil {
+						*flipped = append(*flipped, nextPiece)
 					}
+					nextPiece.i += dir.i
+					nextPiece.j += dir.j
 				}
-			} else {
-				continue
 			}
-		}
-		X.board[piece.i][piece.j] = X.turn // Place the piece after flipping
-
-		// Update score
-		// Total score increase = all flipped pieces + 1 new piece placed
-		if X.turn == 1 {
-			X.blackScore += flippedCount + 1
-			X.whiteScore -= flippedCount
 		} else {
-			X.blackScore -= flippedCount
-			X.whiteScore += flippedCount + 1
+			continue
 		}
+	}
+	X.board[piece.i][piece.j] = int8(X.turn) // Place the piece after flipping
+
+	// Update score
+	// Total score increase = all flipped pieces + 1 new piece placed
+	if X.turn == 1 {
+		X.blackScore += flippedCount + 1
+		X.whiteScore -= flippedCount
+	} else {
+		X.blackScore -= flippedCount
+		X.whiteScore += flippedCount + 1
+	}
 
-		// Update neighbours
-		// First create empty set of neighbours and iterate
-		newNeighbourSet := []Position{}
-		tempNeighbourSet := append(X.neighbours, X.getNeighbour(piece)...)
-		for _, n := range tempNeighbourSet {
-			if n == piece {
+	// Update neighbours
+	// First create empty set of neighbours and iterate
+	newNeighbourSet := []Position{}
+	// Full slice expression so that append never writes into
+	// the neighbours of another Board copied from this one
+	tempNeighbourSet := append(X.neighbours[:len(X.neighbours):len(X.neighbours)], X.getNeighbour(piece)...)
+	for _, n := range tempNeighbourSet {
+		if n == piece {
 
-				// Don't add the piece placed as a neighbour
-				continue
-			} else if posInSlice(n, newNeighbourSet) == false {
-				newNeighbourSet = append(newNeighbourSet, n)
-			} else {
-				continue
-			}
-			X.neighbours = newNeighbourSet
+			// Don't add the piece placed as a neighbour
+			continue
+		} else if posInSlice(n, newNeighbourSet) == false {
+			newNeighbourSet = append(newNeighbourSet, n)
+		} else {
+			continue
 		}
-		X.turn = -X.turn               // Next player's turn
-		X.validSpace = X.getAllValid() // Update valid space for next turn
-
-		// If there are no valid moves for next player
-		// Skip their turn
+	}
+	X.neighbours = newNeighbourSet
+	X.turn = -X.turn               // Next player's turn
+	X.validSpace = X.getAllValid() // Update valid space for next turn
+
+	// If there are no valid moves for next player
+	// Skip their turn
+	if len(X.validSpace) == 0 {
+		X.turn = -X.turn
+		X.validSpace = X.getAllValid()
+
+		// If there are no more moves for both players
+		// End the game
 		if len(X.validSpace) == 0 {
-			X.turn = -X.turn
-			X.validSpace = X.getAllValid()
-
-			// If there are no more moves for both players
-			// End the game
-			if len(X.validSpace) == 0 {
-
-				// Determine Winner
-				if X.blackScore > X.whiteScore {
-					X.winner = 1
-				} else if X.blackScore < X.whiteScore {
-					X.winner = -1
-				} else {
-					X.winner = 99 //Draw case
-				}
-			}
+
+			// Determine Winner
+			X.winner = X.determineWinner()
 		}
 	}
 }
 
+func (X *Board) determineWinner() int {
+	// Winner of a finished game from the scores
+	// The player with more pieces wins, or fewer pieces in the misere variant
+	diff := X.blackScore - X.whiteScore
+	if X.misere {
+		diff = -diff
+	}
+	if diff > 0 {
+		return 1
+	} else if diff < 0 {
+		return -1
+	} else {
+		return 99 //Draw case
+	}
+}
+
+func (X *Board) setVariant(variant string) error {
+	// Set the rule variant of the game by name
+	switch variant {
+	case "", "standard":
+		X.misere = false
+	case "misere":
+		X.misere = true
+	default:
+		return fmt.Errorf("unknown variant %q, expected standard or misere", variant)
+	}
+	return nil
+}
+
 func SetGame(state GameState) Board {
-	// Setup the board for a given game state of 8x8 reversi
+	// Setup the board for a given game state of reversi
+	// Board is 8x8 unless state.BoardSize is given
 	// Used to restore game state from API
 	// Returns a Board
-	Grid := [8][8]int{}
+	size := state.BoardSize
+	if size == 0 {
+		size = 8
+	}
+	Grid := [MaxBoardSize][MaxBoardSize]int8{}
 	for i := 0; i < len(state.BlackFilled); i++ {
 		Grid[state.BlackFilled[i][0]][state.BlackFilled[i][1]] = 1
 	}
 	for j := 0; j < len(state.WhiteFilled); j++ {
 		Grid[state.WhiteFilled[j][0]][state.WhiteFilled[j][1]] = -1
 	}
+	for k := 0; k < len(state.Blocked); k++ {
+		Grid[state.Blocked[k][0]][state.Blocked[k][1]] = blockedSquare
+	}
 	B := Board{
-		length:     8,
+		length:     size,
 		board:      Grid,
 		filled:     []Position{},
 		empty:      []Position{},
@@ -441,6 +607,7 @@ func SetGame(state GameState) Board {
 		turn:       state.Turn,
 	}
 	B.Setup()
+	B.setVariant(state.Variant)
 
 	return B
 }
@@ -448,13 +615,21 @@ func SetGame(state GameState) Board {
 func newGame() Board {
 	// Setup the board for a new game of 8x8 reversi.
 	// Returns a Board
-	Grid := [8][8]int{}
-	Grid[3][3] = -1
-	Grid[4][4] = -1
-	Grid[3][4] = 1
-	Grid[4][3] = 1
+	return newGameSize(8)
+}
+
+func newGameSize(size int) Board {
+	// Setup the board for a new game of size x size reversi.
+	// The four center pieces are placed as on the 8x8 board
+	// Returns a Board
+	c := size / 2
+	Grid := [MaxBoardSize][MaxBoardSize]int8{}
+	Grid[c-1][c-1] = -1
+	Grid[c][c] = -1
+	Grid[c-1][c] = 1
+	Grid[c][c-1] = 1
 	B := Board{
-		length:     8,
+		length:     size,
 		board:      Grid,
 		filled:     []Position{},
 		empty:      []Position{},
@@ -474,9 +649,6 @@ func simRand(game Board) Board {
 	// Given a Board, simulate all moves randomly until end of game
 	for {
 		if game.winner == 0 {
-
-			//rand is deterministic. Need to set seed
-			rand.Seed(time.Now().UTC().UnixNano())
 			move := game.validSpace[rand.Intn(len(game.validSpace))]
 			game.Move(move)
 		} else {
@@ -486,25 +658,24 @@ func simRand(game Board) Board {
 	return game
 }
 
-func simRandPlus(game Board) Board {
+func simRandPlus(game Board, retries int, amaf *amafCounts) Board {
 	// Given a Board, simulate all moves semi-randomly until end of game
 	// Added heuristic to discourage making very bad positions during rollouts
 	// Both sides in simulation will avoid very bad positions
+	// by choosing again up to retries times
+	// Moves are recorded in amaf for RAVE unless it is nil
 	// Alternative to default simRand function
 	for {
 		if game.winner == 0 {
-
-			//rand is deterministic. Need to set seed
-			rand.Seed(time.Now().UTC().UnixNano())
 			move := game.validSpace[rand.Intn(len(game.validSpace))]
 
 			// When a very bad position is chosen,
 			// Choose again, repeat again if very bad position chosen
-			if posInSlice(move, veryBadPositions) == true {
+			for k := 0; k < retries && posInSlice(move, veryBadPositions(game.length)); k++ {
 				move = game.validSpace[rand.Intn(len(game.validSpace))]
-				if posInSlice(move, veryBadPositions) == true {
-					move = game.validSpace[rand.Intn(len(game.validSpace))]
-				}
+			}
+			if amaf != nil {
+				amaf.record(game.turn, move)
 			}
 			game.Move(move)
 		} else {
@@ -514,16 +685,30 @@ func simRandPlus(game Board) Board {
 	return game
 }
 
-func Rollout(game Board, nSim int) (int, int, int, time.Duration) {
+func Rollout(game Board, nSim int, p Params, amaf *amafCounts) (int, int, int, time.Duration) {
 	// Rollout function simulates nSim number of games based on given board situation
 	// Function returns number of games won by black (1), white (-1), and draws and time elapsed for the function call
+	// The squares played in the games are counted in amaf for RAVE unless it is nil
 	turn := game.turn
 	wins := 0
 	draws := 0
 	tempGame := game
 	start := time.Now()
+
+	// Very bad positions are only avoided in standard reversi
+	// In the misere variant giving away corners is good
+	retries := int(math.Round(p.RolloutRetries))
+	if game.misere {
+		retries = 0
+	}
+	if amaf != nil {
+		amaf.reset()
+	}
 	for i := 0; i < nSim; i++ {
-		tempGame = simRandPlus(game)
+		tempGame = simRandPlus(game, retries, amaf)
+		if amaf != nil {
+			amaf.finish(tempGame.winner)
+		}
 		if tempGame.winner == turn {
 			wins++
 		}
@@ -539,14 +724,152 @@ func Rollout(game Board, nSim int) (int, int, int, time.Duration) {
 
 // MCTS CODE
 
+// Numeric parameters of the search heuristics
+// Tunable with the tune command instead of being hard-coded
+type Params struct {
+	Exploration     float64 `json:"exploration"`     // Exploration constant c of UCT
+	InnerWeight     float64 `json:"innerWeight"`     // Weight of the adjustment favouring inner pieces
+	GreedWeight     float64 `json:"greedWeight"`     // Weight of the penalty for flipping many pieces
+	GreedExponent   float64 `json:"greedExponent"`   // Power of total pieces dividing the greed penalty
+	CornerWeight    float64 `json:"cornerWeight"`    // Adjustment for moves on corners
+	BadWeight       float64 `json:"badWeight"`       // Adjustment for moves adjacent to corners
+	VeryBadWeight   float64 `json:"veryBadWeight"`   // Adjustment for moves giving corners away
+	LateGame        float64 `json:"lateGame"`        // Pieces on an 8x8 board after which only UCT is used, scaled to the board size
+	RolloutRetries  float64 `json:"rolloutRetries"`  // Times a rollout chooses again instead of a very bad position (rounded)
+	RaveEquivalence float64 `json:"raveEquivalence"` // Visits at which UCT and AMAF values are weighted equally, 0 disables RAVE
+	PUCT            float64 `json:"puct"`            // Exploration constant of PUCT selection with move priors, 0 uses UCT
+	Prior           string  `json:"prior"`           // Name of the prior provider for PUCT, see priorProviders, or "network"
+	Network         string  `json:"network"`         // Weights file of the network for the network prior and leaf evaluation
+	Patterns        string  `json:"patterns"`        // Weights file of the pattern evaluation for the patterns prior and leaf evaluation
+	ValueWeight     float64 `json:"valueWeight"`     // Weight of the network or pattern value against rollouts at leaves, 1 replaces rollouts
+	FinalPolicy     string  `json:"finalPolicy"`     // Policy to select the final move, see finalPolicies, heuristic if empty
+	MaxNodes        float64 `json:"maxNodes"`        // Nodes in the search tree above which the least visited subtrees are pruned, 0 for no limit (rounded)
+	Transpositions  float64 `json:"transpositions"`  // Above 0, nodes of transposed and symmetric positions share their statistics, see tree.go
+	Book            string  `json:"book"`            // Opening book file, positions in it are played from the book without searching
+}
+
+// Parameters the agent has been playing with
+var DefaultParams = Params{
+	Exploration:     3,
+	InnerWeight:     1,
+	GreedWeight:     1,
+	GreedExponent:   4,
+	CornerWeight:    1.5,
+	BadWeight:       -0.55,
+	VeryBadWeight:   -100,
+	LateGame:        50,
+	RolloutRetries:  2,
+	RaveEquivalence: 0,
+	MaxNodes:        200000,
+}
+
+type paramField struct {
+	name  string
+	value func(p *Params) *float64
+	min   float64 // Range and step size used when tuning
+	max   float64
+	step  float64
+}
+
+// Parameters that can be set by name, e.g. in agent specifications and the tuner
+var paramFields = []paramField{
+	{"exploration", func(p *Params) *float64 { return &p.Exploration }, 0, 10, 0.5},
+	{"innerWeight", func(p *Params) *float64 { return &p.InnerWeight }, 0, 5, 0.2},
+	{"greedWeight", func(p *Params) *float64 { return &p.GreedWeight }, 0, 5, 0.2},
+	{"greedExponent", func(p *Params) *float64 { return &p.GreedExponent }, 0, 6, 0.3},
+	{"cornerWeight", func(p *Params) *float64 { return &p.CornerWeight }, 0, 5, 0.2},
+	{"badWeight", func(p *Params) *float64 { return &p.BadWeight }, -5, 1, 0.1},
+	{"veryBadWeight", func(p *Params) *float64 { return &p.VeryBadWeight }, -200, 0, 10},
+	{"lateGame", func(p *Params) *float64 { return &p.LateGame }, 0, 64, 3},
+	{"rolloutRetries", func(p *Params) *float64 { return &p.RolloutRetries }, 0, 5, 0.5},
+	{"raveEquivalence", func(p *Params) *float64 { return &p.RaveEquivalence }, 0, 2000, 50},
+	{"puct", func(p *Params) *float64 { return &p.PUCT }, 0, 10, 0.25},
+	{"valueWeight", func(p *Params) *float64 { return &p.ValueWeight }, 0, 1, 0.1},
+	{"maxNodes", func(p *Params) *float64 { return &p.MaxNodes }, 0, 1000000, 10000},
+	{"transpositions", func(p *Params) *float64 { return &p.Transpositions }, 0, 1, 1},
+}
+
+func findParamField(name string) (paramField, error) {
+	for _, f := range paramFields {
+		if f.name == name {
+			return f, nil
+		}
+	}
+	return paramField{}, fmt.Errorf("unknown parameter %q", name)
+}
+
+func (p *Params) Set(name string, value float64) error {
+	// Set a parameter by its name
+	f, err := findParamField(name)
+	if err != nil {
+		return err
+	}
+	*f.value(p) = value
+	return nil
+}
+
+func (p *Params) SetString(name string, value string) error {
+	// Set a parameter by its name from a string, as given on the command line
+	// Besides the numeric parameters, accepts policy and prior by name,
+	// network and patterns as the paths of weights files
+	// and book as the path of an opening book
+	switch name {
+	case "policy":
+		p.FinalPolicy = value
+		return validFinalPolicy(value)
+	case "prior":
+		p.Prior = value
+		if value == "network" || value == "patterns" {
+			return nil
+		}
+		_, err := findPrior(value)
+		return err
+	case "network":
+		p.Network = value
+		_, err := cachedNetwork(value)
+		return err
+	case "patterns":
+		p.Patterns = value
+		_, err := cachedPatternWeights(value)
+		return err
+	case "book":
+		p.Book = value
+		_, err := cachedOpeningBook(value)
+		return err
+	}
+	v, err := strconv.ParseFloat(value, 64)
+	if err != nil {
+		return fmt.Errorf("invalid value %q for %s", value, name)
+	}
+	return p.Set(name, v)
+}
+
+func (p Params) Get(name string) (float64, error) {
+	// Get a parameter by its name
+	f, err := findParamField(name)
+	if err != nil {
+		return 0, err
+	}
+	return *f.value(&p), nil
+}
+
 type Node struct {
-	position Position // Position evaluated at node
-	state    Board    // State of Board after position is evaluated
-	parent   *Node    // Parent of node
-	children []*Node  // Slice of children Nodes
-	played   int      // No. of times node was visited
-	wins     int      // No. of times won / score
-	depth    int      // Depth of tree - root is 0
+	position Position  // Position evaluated at node
+	state    nodeState // State of Board after position is evaluated, see tree.go
+	parent   *Node     // Parent of node
+	children []*Node   // Slice of children Nodes
+	played   int       // No. of times node was visited
+	wins     int       // No. of times won / score
+	depth    int       // Depth of tree - root is 0
+	proven   int       // Proven result for the side to move - Win (1), Loss (-1), Draw (99), Unproven (0)
+	pool     *nodePool // Allocator of the nodes of the tree, only set on the root
+
+	transposition *transposition // Statistics shared with other nodes of the same position, nil without a table
+
+	amafWins   int // All-moves-as-first wins of position for RAVE
+	amafPlayed int // No. of rollouts below the parent in which position was played by the parent's side to move
+
+	prior float64 // Prior probability of position for PUCT, set when the parent is expanded
 
 	mobility float64 // Raw Mobility score:
 	//     The Accumulated count of valid space from explored nodes
@@ -557,46 +880,227 @@ type Node struct {
 	//     1 opponent piece can only have max 4 valid spaces to flip
 }
 
-func (n *Node) expandNode() {
+func (n *Node) expandNode(provider PriorProvider, pool *nodePool) {
 	// Function to expand node to have children
 	// Takes in validSpace array of positions from Board
+	// Priors of the children are computed by provider unless it is nil
+	// Moves symmetric to an earlier move get no child of their own,
+	// their prior is added to the child of the earlier move
+	// Children are allocated from pool, and start from the statistics
+	// of their position in the transposition table of pool if it has one
 	// Updates current Node
+	game := n.state.Board()
 	children := []*Node{}
-	for i := 0; i < len(n.state.validSpace); i++ {
-		gameState := n.state // Deep copy of parent game state
-		gameState.Move(n.state.validSpace[i])
-		children = append(
-			children,
-			&Node{
-				state:    gameState,
-				position: n.state.validSpace[i],
-				wins:     0,
-				depth:    n.depth + 1,
-				parent:   n,
-			},
-		)
+	var priors []float64
+	if provider != nil {
+		priors = provider.Priors(game)
+	}
+	representatives := game.moveRepresentatives()
+	childOf := map[int]*Node{}
+	for i := 0; i < len(game.validSpace); i++ {
+		if r := representatives[i]; r != i {
+			if priors != nil {
+				childOf[r].prior += priors[i]
+			}
+			continue
+		}
+		gameState := game // Copy of parent game state, see Clone
+		gameState.Move(game.validSpace[i])
+		child := pool.get()
+		*child = Node{
+			state:    gameState.compact(),
+			position: game.validSpace[i],
+			wins:     0,
+			depth:    n.depth + 1,
+			parent:   n,
+			proven:   terminalResult(gameState),
+		}
+		if priors != nil {
+			child.prior = priors[i]
+		}
+		if pool.table != nil {
+			child.transposition = pool.table.lookup(gameState)
+			child.played = child.transposition.played
+			child.wins = child.transposition.wins
+		}
+		children = append(children, child)
+		childOf[i] = child
 	}
 	n.children = children
 }
 
-func UCT(w, n, N, c int) float64 {
+func terminalResult(state Board) int {
+	// Result of a finished game for the side to move
+	// Unproven (0) if the game is not finished
+	switch state.winner {
+	case 0, 99:
+		return state.winner
+	case state.turn:
+		return 1
+	default:
+		return -1
+	}
+}
+
+func (n *Node) childResult(child *Node) int {
+	// Proven result of child for the side to move at n
+	// The side to move is the same at both nodes when the opponent passes
+	if child.proven == 99 || child.state.turn == n.state.turn {
+		return child.proven
+	}
+	return -child.proven
+}
+
+func updateProven(n *Node) {
+	// MCTS-Solver: prove n and its ancestors from their children
+	// A node is a proven win if any child is a win for its side to move
+	// Once all children are proven, it takes the best result among them
+	for ; n != nil && n.proven == 0 && len(n.children) > 0; n = n.parent {
+		win, draw, unproven := false, false, false
+		for _, child := range n.children {
+			switch n.childResult(child) {
+			case 1:
+				win = true
+			case 99:
+				draw = true
+			case 0:
+				unproven = true
+			}
+		}
+		switch {
+		case win:
+			n.proven = 1
+		case unproven:
+			return
+		case draw:
+			n.proven = 99
+		default:
+			n.proven = -1
+		}
+	}
+}
+
+// Policies to select the final move once the search is done
+// heuristic: selectChild without exploration, including the position heuristics
+// visits:    the most visited child (robust child)
+// winrate:   the child with the highest win rate (max child)
+// maxrobust: the most visited child once it also has the highest win rate,
+// searchTree searches on until it has, for up to half the iterations or
+// time of the search again, and falls back to visits after that
+// secure:    the child with the highest lower confidence bound of the win rate
+var finalPolicies = []string{"heuristic", "visits", "winrate", "maxrobust", "secure"}
+
+func validFinalPolicy(policy string) error {
+	if policy == "" {
+		return nil
+	}
+	for _, name := range finalPolicies {
+		if policy == name {
+			return nil
+		}
+	}
+	return fmt.Errorf("unknown final move policy %q, expected one of %v", policy, finalPolicies)
+}
+
+func (n *Node) childRate(child *Node) float64 {
+	// Win rate of child for the side to move at n
+	// Draws count as not lost when the side to move changes
+	if child.played == 0 {
+		return 0
+	}
+	rate := float64(child.wins) / float64(child.played)
+	if child.state.turn != n.state.turn {
+		rate = 1 - rate
+	}
+	return rate
+}
+
+func (n *Node) finalChild(p Params) (*Node, string) {
+	// Final move selection with the policy p.FinalPolicy
+	// A proven win is always played and a proven loss only when nothing else is left
+	// Returns the child and the policy that decided on it
+	candidates := []*Node{}
+	for _, child := range n.children {
+		switch n.childResult(child) {
+		case 1:
+			return child, "proven"
+		case 0, 99:
+			candidates = append(candidates, child)
+		}
+	}
+	if len(candidates) == 0 {
+		candidates = n.children
+	}
+	best := func(score func(child *Node) float64) *Node {
+		choice := candidates[0]
+		for _, child := range candidates[1:] {
+			if score(child) > score(choice) {
+				choice = child
+			}
+		}
+		return choice
+	}
+	visits := func(child *Node) float64 { return float64(child.played) }
+	rate := func(child *Node) float64 { return n.childRate(child) }
+
+	switch p.FinalPolicy {
+	case "visits":
+		return best(visits), "visits"
+	case "winrate":
+		return best(rate), "winrate"
+	case "maxrobust":
+		choice := best(visits)
+		if rate(choice) >= rate(best(rate)) {
+			return choice, "maxrobust"
+		}
+		return choice, "visits"
+	case "secure":
+		return best(func(child *Node) float64 {
+			return rate(child) - 1/math.Sqrt(float64(child.played+1))
+		}), "secure"
+	}
+	choice := n.selectChild(0, "min", p)
+	if n.childResult(choice) == -1 && len(candidates) < len(n.children) {
+		return best(visits), "visits"
+	}
+	return choice, "heuristic"
+}
+
+func (n *Node) maxRobustFound(p Params) bool {
+	// Whether the final child is decided without falling back to visits
+	_, policy := n.finalChild(p)
+	return policy != "visits"
+}
+
+func UCT(w, n, N int, c float64) float64 {
 	// The Upper Confidence Bound  applied to Trees
 	uct := float64(w)/float64(n+1) +
-		math.Sqrt(float64(c))*math.Sqrt(math.Log(float64(N+1))/float64(n+1))
+		math.Sqrt(c)*math.Sqrt(math.Log(float64(N+1))/float64(n+1))
 	return uct
 }
 
-func (n *Node) selectChild(N int, best string) *Node {
+func (n *Node) selectChild(N int, best string, p Params) *Node {
 	// Selection phase for agent to choose node
 	// and decide on which Position to move
 	// N = # of games played overall
 	// Node selction based on upper confidence bound UCT
+	// Heuristic adjustments are weighted by p
+	// With p.PUCT set, the priors of the children are used instead
+	if p.PUCT > 0 {
+		return n.selectPUCT(best, p)
+	}
 	index_best_score := 0
 	best_uctScore := -0.00
 	totalUCTScore := 0.00
 	uctScore := 0.00
 	// var uctScore float64
 
+	// Heuristics are derived from the board size
+	// Center of the board for the inner score
+	// and late game threshold scaled from 64 squares
+	center := float64(n.state.length-1) / 2
+	lateGame := p.LateGame * float64(n.state.length*n.state.length) / 64
+
 	if best == "max" {
 		best_uctScore = -9999.00
 	}
@@ -604,7 +1108,18 @@ func (n *Node) selectChild(N int, best string) *Node {
 		best_uctScore = 9999.0
 	}
 	for i, child := range n.children {
-		uctScore = UCT(child.wins, child.played, N, 3)
+		// Proven nodes are not sampled any more
+		if best == "max" && child.proven != 0 {
+			continue
+		}
+		uctScore = UCT(child.wins, child.played, N, p.Exploration)
+		if p.RaveEquivalence > 0 {
+
+			// RAVE: blend the win rate with the AMAF win rate of the square
+			// The AMAF value counts the most while the node has few visits
+			beta := math.Sqrt(p.RaveEquivalence / (3*float64(child.played) + p.RaveEquivalence))
+			uctScore += beta * (float64(child.amafWins)/float64(child.amafPlayed+1) - float64(child.wins)/float64(child.played+1))
+		}
 
 		// Adjustment score for mobility
 		// Greater mobility translates to more available moves to make
@@ -615,41 +1130,48 @@ func (n *Node) selectChild(N int, best string) *Node {
 		// Inner pieces, or pieces close to the center of the board
 		// have a higher value as they allow for more connections
 		// to all other parts of the board
-		innerScore := uctScore * 1 / math.Sqrt((math.Pow((float64(child.position.i)-3.5), 2) + math.Pow((float64(child.position.j)-3.5), 2)))
+		innerScore := uctScore * p.InnerWeight / math.Sqrt((math.Pow((float64(child.position.i)-center), 2) + math.Pow((float64(child.position.j)-center), 2)))
 		// Penalty for greed
 		// Squared denominator penalizes early game greed more heavily
 		// Flipping more pieces early in the game is generally a bad strategy
 		// Greediness gives less mobility in early to mid-games
 		greedPenalty := 0.00
 		if n.state.turn == 1 {
-			greedPenalty = uctScore * float64(child.state.blackScore-n.state.blackScore) / math.Pow(float64(child.state.blackScore+child.state.whiteScore), 4)
+			greedPenalty = p.GreedWeight * uctScore * float64(child.state.blackScore-n.state.blackScore) / math.Pow(float64(child.state.blackScore+child.state.whiteScore), p.GreedExponent)
 		}
 		if n.state.turn == -1 {
-			greedPenalty = uctScore * float64(child.state.whiteScore-n.state.whiteScore) / math.Pow(float64(child.state.blackScore+child.state.whiteScore), 4)
+			greedPenalty = p.GreedWeight * uctScore * float64(child.state.whiteScore-n.state.whiteScore) / math.Pow(float64(child.state.blackScore+child.state.whiteScore), p.GreedExponent)
 		}
 
 		// Adjustment score to account for generally good / bad positions
 		// - Encourages making moves that are corners
 		// - Discourages making moves that give away corners
 		positionScore := 0.00
-		for _, corner := range corners {
+		for _, corner := range corners(n.state.length) {
 			if child.position == corner {
-				positionScore = uctScore * 1.5
+				positionScore = uctScore * p.CornerWeight
 			}
 		}
-		for _, badpos := range badPositions {
+		for _, badpos := range badPositions(n.state.length) {
 			if child.position == badpos {
-				positionScore = uctScore * (-0.55)
+				positionScore = uctScore * p.BadWeight
 			}
 		}
-		for _, badpos := range veryBadPositions {
+		for _, badpos := range veryBadPositions(n.state.length) {
 			if child.position == badpos {
-				positionScore = uctScore * (-100)
+				positionScore = uctScore * p.VeryBadWeight
 			}
 		}
+
+		// In the misere variant the player with fewer pieces wins
+		// Corners are bad and giving corners away is good
+		// Flipping many pieces stays penalized, it is even worse than in the standard game
+		if n.state.misere {
+			positionScore = -positionScore
+		}
 		if best == "max" {
 
-			if child.state.blackScore+child.state.whiteScore > 50 {
+			if float64(child.state.blackScore+child.state.whiteScore) > lateGame {
 				totalUCTScore = uctScore
 			} else {
 				totalUCTScore = uctScore + innerScore + positionScore - greedPenalty
@@ -661,7 +1183,7 @@ func (n *Node) selectChild(N int, best string) *Node {
 		}
 		if best == "min" {
 
-			if child.state.blackScore+child.state.whiteScore > 50 {
+			if float64(child.state.blackScore+child.state.whiteScore) > lateGame {
 				totalUCTScore = uctScore
 			} else {
 				totalUCTScore = uctScore - innerScore - positionScore + greedPenalty
@@ -680,16 +1202,21 @@ func backProp(n *Node, wins int, loss int, played int) {
 
 	// Backpropagation to traverse from child to parent nodes
 	// Update count of wins and played games starting from Node n
+	// and the transposition table entries of the nodes
 	turn := n.state.turn // which are the wins referring to: (black:1, white:-1)
-	mobility := float64(len(n.state.validSpace))
+	mobility := float64(n.state.moves)
 	for {
+		result := loss
 		if n.state.turn == turn {
-			n.wins += wins
+			result = wins
 			n.mobility += mobility
-		} else {
-			n.wins += loss
 		}
+		n.wins += result
 		n.played += played
+		if t := n.transposition; t != nil {
+			t.wins += result
+			t.played += played
+		}
 		if n.parent == nil {
 			break
 		} else {
@@ -699,36 +1226,134 @@ func backProp(n *Node, wins int, loss int, played int) {
 }
 
 func Search(root Node, nSims int, max_iter int) Position {
+	// Search with the default parameters
+	return SearchWith(root, nSims, max_iter, DefaultParams)
+}
+
+func SearchWith(root Node, nSims int, max_iter int, p Params) Position {
+	// Search for max_iter iterations
+	decision, _ := searchTree(&root, nSims, max_iter, time.Time{}, nil, p)
+	return decision
+}
+
+func SearchDecision(root Node, nSims int, max_iter int, p Params) (Position, string) {
+	// Search for max_iter iterations
+	// Also returns the policy that selected the final move
+	return searchTree(&root, nSims, max_iter, time.Time{}, nil, p)
+}
+
+func SearchTimed(root Node, nSims int, budget time.Duration, p Params) Position {
+	// Search for as many iterations as fit in the time budget
+	decision, _ := searchTree(&root, nSims, math.MaxInt, time.Now().Add(budget), nil, p)
+	return decision
+}
+
+func searchStopped(deadline time.Time, stop <-chan struct{}) bool {
+	// Whether a search has passed its deadline or has been stopped
+	if !deadline.IsZero() && time.Now().After(deadline) {
+		return true
+	}
+	select {
+	case <-stop:
+		return true
+	default:
+		return false
+	}
+}
+
+func searchTree(root *Node, nSims int, max_iter int, deadline time.Time, stop <-chan struct{}, p Params) (Position, string) {
 
 	// Main function of agent to search for the optimal move
 	// Expands children nodes and traverses down the tree to leaf node
 	// Simulates games and backpropagates results
-	// Across max_iter iterations
+	// Across max_iter iterations, or until the deadline if one is given
+	// or stop is closed
+	// With the maxrobust policy the search is extended once if the most
+	// visited child does not have the highest win rate, see finalPolicies
 	// After which, selects the next move based on selectChild function
-	N := 0
+	// A root that was searched before (e.g. while pondering) keeps its tree
+	// Positions in the opening book of p.Book are not searched, the most
+	// played book move is returned with the policy "book"
+	if p.Book != "" {
+		if book, err := cachedOpeningBook(p.Book); err == nil {
+			if move, ok := book.Move(root.state.Board()); ok {
+				return move, "book"
+			}
+		}
+	}
+	N := root.played
+	start := time.Now()
+	extended := false
 	wins := 0
 	loss := 0
 	// minScore := 999.9 // Any val greter than 1
 	decision := Position{0, 0}
-	root.expandNode()
-	currentNode := root.selectChild(N, "min")
-	wins, loss, _, _ = Rollout(currentNode.state, nSims)
-	backProp(currentNode, wins, loss, nSims)
-	N += nSims // Update total number of simulations
+	var currentNode *Node
+	var amaf *amafCounts
+	if p.RaveEquivalence > 0 {
+		amaf = &amafCounts{}
+	}
+	eval := leafEvaluatorFor(p)
+	if root.pool == nil {
+		root.pool = &nodePool{used: countNodes(root)}
+	}
+	pool := root.pool
+	maxNodes := int(math.Round(p.MaxNodes))
+	if p.Transpositions <= 0 {
+		pool.table = nil
+	} else if pool.table == nil {
+		pool.table = transpositionTable{}
+	}
+	var provider PriorProvider
+	if p.PUCT > 0 {
+		provider, _ = priorFor(p)
+	}
+	if len(root.children) == 0 {
+		root.expandNode(provider, pool)
+		updateProven(root)
+		currentNode = root.selectChild(N, "min", p)
+		wins, loss = evaluateLeaf(currentNode.state.Board(), nSims, p, amaf, eval)
+		backProp(currentNode, wins, loss, nSims)
+		backPropAMAF(currentNode, amaf, nSims)
+		N += nSims // Update total number of simulations
+	}
 
 	// Each iteration traverses down the tree
 	// From parent to leaf node
 	// Path of selections based on selectChild function
 	// Once leaf node is reached, commence Rollout to simulate games
-	for iter := 0; iter < max_iter; iter++ {
+	for iter := 0; ; iter++ {
+		if searchStopped(time.Time{}, stop) || root.proven != 0 {
+			break
+		}
+		if iter >= max_iter || searchStopped(deadline, nil) {
+			if p.FinalPolicy != "maxrobust" || extended || root.maxRobustFound(p) {
+				break
+			}
+
+			// Search on for up to half of the iterations or time so far
+			extended = true
+			if iter >= max_iter {
+				max_iter = iter + iter/2 + 1
+			}
+			if !deadline.IsZero() {
+				deadline = time.Now().Add(time.Since(start) / 2)
+			}
+		}
+		if extended && root.maxRobustFound(p) {
+			break
+		}
+		if maxNodes > 0 && pool.used > maxNodes {
+			pool.prune(root, maxNodes)
+		}
 
 		// Keep selecting child nodes until leaf node is reached.
-		currentNode = root.selectChild(N, "max")
+		currentNode = root.selectChild(N, "max", p)
 		for {
 			if len(currentNode.children) == 0 {
 				break
 			} else {
-				currentNode = currentNode.selectChild(N, "max")
+				currentNode = currentNode.selectChild(N, "max", p)
 			}
 		}
 
@@ -737,22 +1362,29 @@ func Search(root Node, nSims int, max_iter int) Position {
 
 			// If no games played yet on this node -> rollout
 			// Then backpropagate results
-			wins, loss, _, _ = Rollout(currentNode.state, nSims)
+			wins, loss = evaluateLeaf(currentNode.state.Board(), nSims, p, amaf, eval)
 			N += nSims
 			backProp(currentNode, wins, loss, nSims)
+			backPropAMAF(currentNode, amaf, nSims)
 			N += nSims
 		} else {
 
 			// When leaf node has been simulated before
 			// Expand and look for children
-			currentNode.expandNode()
-			if len(currentNode.children) == 0 {
+			currentNode.expandNode(provider, pool)
+			updateProven(currentNode)
+			if currentNode.proven != 0 {
+
+				// The children decide the game, nothing left to simulate
+				continue
+			} else if len(currentNode.children) == 0 {
 
 				// If there are no more children left
 				// Simulate currentNode again and backpropagate
-				wins, loss, _, _ = Rollout(currentNode.state, nSims)
+				wins, loss = evaluateLeaf(currentNode.state.Board(), nSims, p, amaf, eval)
 				N += nSims
 				backProp(currentNode, wins, loss, nSims)
+				backPropAMAF(currentNode, amaf, nSims)
 				N += nSims
 
 			} else {
@@ -760,10 +1392,11 @@ func Search(root Node, nSims int, max_iter int) Position {
 				// If expansion yields children,
 				// Select a child and commence rollout on child node
 				// Backpropate from child node
-				currentNode = currentNode.selectChild(N, "max")
-				wins, loss, _, _ = Rollout(currentNode.state, nSims)
+				currentNode = currentNode.selectChild(N, "max", p)
+				wins, loss = evaluateLeaf(currentNode.state.Board(), nSims, p, amaf, eval)
 				N += nSims
 				backProp(currentNode, wins, loss, nSims)
+				backPropAMAF(currentNode, amaf, nSims)
 				N += nSims
 			}
 		}
@@ -772,9 +1405,10 @@ func Search(root Node, nSims int, max_iter int) Position {
 	// Once all simulation and max iterations reached
 	// Select the child from the root node
 	// This will be the move the agent makes
-	decision = root.selectChild(0, "min").position
+	choice, policy := root.finalChild(p)
+	decision = choice.position
 
-	return decision
+	return decision, policy
 }
 
 type simResults struct {
@@ -793,7 +1427,7 @@ func Simulator(N int, nSims int, max_iter int) simResults {
 	for nGames := 0; nGames < N; nGames++ {
 		game := newGame()
 		root := Node{
-			state: game,
+			state: game.compact(),
 			depth: 0,
 		}
 		move := Position{0, 0}
@@ -801,18 +1435,17 @@ func Simulator(N int, nSims int, max_iter int) simResults {
 		for {
 			if game.winner == 0 {
 				if game.turn == 1 {
-					root = Node{state: game, depth: 0}
+					root = Node{state: game.compact(), depth: 0}
 					move = Search(root, nSims, max_iter)
 					game.Move(move)
 					fmt.Println("Black moves: ", move.PrintPrettifyNotation(), game.blackScore, game.whiteScore)
 				} else {
-					rand.Seed(time.Now().UTC().UnixNano())
 					move = game.validSpace[rand.Intn(len(game.validSpace))]
 					// When a very bad position is chosen,
 					// Choose again, repeat again if very bad position chosen
-					if posInSlice(move, veryBadPositions) == true {
+					if posInSlice(move, veryBadPositions(game.length)) == true {
 						move = game.validSpace[rand.Intn(len(game.validSpace))]
-						if posInSlice(move, veryBadPositions) == true {
+						if posInSlice(move, veryBadPositions(game.length)) == true {
 							move = game.validSpace[rand.Intn(len(game.validSpace))]
 						}
 					}
@@ -859,24 +1492,22 @@ func RandomRandomPlay(N int) {
 		for {
 			if game.winner == 0 {
 				if game.turn == 1 {
-					rand.Seed(time.Now().UTC().UnixNano())
 					move = game.validSpace[rand.Intn(len(game.validSpace))]
 					// When a very bad position is chosen,
 					// Choose again, repeat again if very bad position chosen
-					if posInSlice(move, veryBadPositions) == true {
+					if posInSlice(move, veryBadPositions(game.length)) == true {
 						move = game.validSpace[rand.Intn(len(game.validSpace))]
-						if posInSlice(move, veryBadPositions) == true {
+						if posInSlice(move, veryBadPositions(game.length)) == true {
 							move = game.validSpace[rand.Intn(len(game.validSpace))]
 						}
 					}
 				} else {
-					rand.Seed(time.Now().UTC().UnixNano())
 					move = game.validSpace[rand.Intn(len(game.validSpace))]
 					// When a very bad position is chosen,
 					// Choose again, repeat again if very bad position chosen
-					if posInSlice(move, veryBadPositions) == true {
+					if posInSlice(move, veryBadPositions(game.length)) == true {
 						move = game.validSpace[rand.Intn(len(game.validSpace))]
-						if posInSlice(move, veryBadPositions) == true {
+						if posInSlice(move, veryBadPositions(game.length)) == true {
 							move = game.validSpace[rand.Intn(len(game.validSpace))]
 						}
 					}
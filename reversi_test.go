@@ -0,0 +1,348 @@
+package main
+
+import (
+	"math/rand"
+	"sort"
+	"testing"
+	"time"
+)
+
+func randomGame(r *rand.Rand, size int, plies int) Board {
+	// Position after up to plies random moves from the start of a size x size game
+	game := newGameSize(size)
+	for ; plies > 0 && game.winner == 0; plies-- {
+		game.Move(game.validSpace[r.Intn(len(game.validSpace))])
+	}
+	return game
+}
+
+func sortedPositions(list []Position) []Position {
+	sorted := append([]Position{}, list...)
+	sort.Slice(sorted, func(a, b int) bool {
+		if sorted[a].i != sorted[b].i {
+			return sorted[a].i < sorted[b].i
+		}
+		return sorted[a].j < sorted[b].j
+	})
+	return sorted
+}
+
+func samePositions(a []Position, b []Position) bool {
+	a, b = sortedPositions(a), sortedPositions(b)
+	if len(a) != len(b) {
+		return false
+	}
+	for k := range a {
+		if a[k] != b[k] {
+			return false
+		}
+	}
+	return true
+}
+
+func TestFlipsAllDirections(t *testing.T) {
+	// A white piece bracketed by black in every direction from D4
+	// and a white piece followed by an empty square that is not
+	Grid := [MaxBoardSize][MaxBoardSize]int8{}
+	move := Position{3, 3}
+	for _, dir := range Directions {
+		Grid[move.i+dir.i][move.j+dir.j] = -1
+		Grid[move.i+2*dir.i][move.j+2*dir.j] = 1
+	}
+	Grid[7][1] = -1
+	game := Board{length: 8, board: Grid, turn: 1}
+	game.Setup()
+	game.Move(move)
+	for _, dir := range Directions {
+		if game.board[move.i+dir.i][move.j+dir.j] != 1 {
+			t.Errorf("piece in direction %v not flipped", dir)
+		}
+	}
+	if game.board[7][1] != -1 {
+		t.Errorf("piece not bracketed was flipped")
+	}
+	if game.blackScore != 17 || game.whiteScore != 1 {
+		t.Errorf("scores %d-%d, want 17-1", game.blackScore, game.whiteScore)
+	}
+}
+
+func TestNoFlipAcrossEdge(t *testing.T) {
+	// Lines end at the edge of the board and do not wrap to the next row
+	wrap := mustParseBoard(t, "------OOX------------------------------------------------------- X")
+	if posInSlice(Position{0, 5}, wrap.validSpace) {
+		t.Errorf("F1 is valid by wrapping around to A2")
+	}
+	edge := mustParseBoard(t, "-----OOX-------------------------------------------------------- X")
+	if posInSlice(Position{0, 4}, edge.validSpace) == false {
+		t.Fatalf("E1 should be valid")
+	}
+	edge.Move(Position{0, 4})
+	if edge.blackScore != 4 || edge.whiteScore != 0 {
+		t.Errorf("scores %d-%d after flipping along the edge, want 4-0", edge.blackScore, edge.whiteScore)
+	}
+}
+
+func TestPass(t *testing.T) {
+	// After C8 black has no valid move and white plays again
+	game := mustParseBoard(t, perftPositions[0].position)
+	turn := game.turn
+	game.Move(Position{7, 2})
+	if game.winner != 0 {
+		t.Fatalf("game ended, winner %d", game.winner)
+	}
+	if game.turn != turn {
+		t.Errorf("turn %d, want %d after the opponent passes", game.turn, turn)
+	}
+}
+
+func TestGameEnd(t *testing.T) {
+	// H8 flips H5-H7 and E5-G7 and fills the board
+	game := mustParseBoard(t, "XXXXXXXXXXXXXXXXXXXXXXXXXXXXXXXXOOOOOOOOOOOOOOOOOOOOOOOOOOOOOOO- X")
+	misere := game
+	misere.misere = true
+	game.Move(Position{7, 7})
+	if game.blackScore != 39 || game.whiteScore != 25 {
+		t.Errorf("scores %d-%d, want 39-25", game.blackScore, game.whiteScore)
+	}
+	if game.winner != 1 {
+		t.Errorf("winner %d, want black", game.winner)
+	}
+	misere.Move(Position{7, 7})
+	if misere.winner != -1 {
+		t.Errorf("misere winner %d, want white", misere.winner)
+	}
+	draw := Board{blackScore: 32, whiteScore: 32}
+	if w := draw.determineWinner(); w != 99 {
+		t.Errorf("winner %d, want draw", w)
+	}
+}
+
+func TestScores(t *testing.T) {
+	// The incremental scores match a count of the board after every move
+	r := rand.New(rand.NewSource(1))
+	for g := 0; g < 50; g++ {
+		game := newGameSize(4 + 2*r.Intn(4))
+		for game.winner == 0 {
+			game.Move(game.validSpace[r.Intn(len(game.validSpace))])
+			black, white := game.getScores()
+			if black != game.blackScore || white != game.whiteScore {
+				t.Fatalf("scores %d-%d, board has %d-%d", game.blackScore, game.whiteScore, black, white)
+			}
+		}
+		if game.winner != game.determineWinner() {
+			t.Errorf("winner %d, scores %d-%d", game.winner, game.blackScore, game.whiteScore)
+		}
+	}
+}
+
+func TestSetGameRoundTrip(t *testing.T) {
+	r := rand.New(rand.NewSource(2))
+	for g := 0; g < 50; g++ {
+		game := randomGame(r, 4+2*r.Intn(4), r.Intn(30))
+		if game.winner != 0 {
+			continue
+		}
+		game.misere = r.Intn(2) == 0
+		state := game.GameState()
+		state.Position = ""
+		for _, restored := range []Board{SetGame(state), mustParseBoard(t, game.PositionString())} {
+			if restored.PositionString() != game.PositionString() {
+				t.Fatalf("restored %s, want %s", restored.PositionString(), game.PositionString())
+			}
+			if restored.blackScore != game.blackScore || restored.whiteScore != game.whiteScore {
+				t.Errorf("scores %d-%d, want %d-%d", restored.blackScore, restored.whiteScore, game.blackScore, game.whiteScore)
+			}
+			if !samePositions(restored.validSpace, game.validSpace) {
+				t.Errorf("valid moves %v, want %v", restored.validSpace, game.validSpace)
+			}
+			if !samePositions(restored.neighbours, game.neighbours) {
+				t.Errorf("neighbours %v, want %v", restored.neighbours, game.neighbours)
+			}
+		}
+		if SetGame(state).misere != game.misere {
+			t.Errorf("variant not restored")
+		}
+	}
+}
+
+func mustParseBoard(t *testing.T, position string) Board {
+	game, err := ParseBoard(position)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return game
+}
+
+func TestSearchLegal(t *testing.T) {
+	r := rand.New(rand.NewSource(3))
+	puct := DefaultParams
+	puct.PUCT = 1.5
+	for g := 0; g < 10; g++ {
+		game := randomGame(r, 4+2*r.Intn(3), r.Intn(40))
+		if game.winner != 0 {
+			continue
+		}
+		for _, p := range []Params{DefaultParams, puct} {
+			move := SearchWith(Node{state: game.compact()}, 2, 30, p)
+			if posInSlice(move, game.validSpace) == false {
+				t.Errorf("%s: search returned %s, not a valid move", game.PositionString(), move.Notation())
+			}
+		}
+	}
+}
+
+func FuzzMoves(f *testing.F) {
+	// Random move sequences checked against the reference move generator
+	// The first byte selects the board size, each next byte a valid move
+	f.Add([]byte{})
+	f.Add([]byte{0, 1, 2, 3, 4, 5, 6, 7, 8, 9})
+	f.Add([]byte{2, 7, 7, 7, 7, 7, 7, 7, 7, 7, 7, 7, 7, 7, 7, 7, 7})
+	f.Add([]byte{6, 255, 3, 128, 64, 9})
+	f.Fuzz(func(t *testing.T, data []byte) {
+		size := 8
+		if len(data) > 0 {
+			size = MinBoardSize + 2*(int(data[0])%((MaxBoardSize-MinBoardSize)/2+1))
+			data = data[1:]
+		}
+		game := newGameSize(size)
+		reference := game.board
+		turn := game.turn
+		for _, b := range data {
+			if game.winner != 0 {
+				break
+			}
+			move := game.validSpace[int(b)%len(game.validSpace)]
+			flips := naiveFlips(&reference, size, turn, move.i, move.j)
+			if len(flips) == 0 {
+				t.Fatalf("%s is valid but flips nothing", move.Notation())
+			}
+			reference[move.i][move.j] = int8(turn)
+			for _, s := range flips {
+				reference[s.i][s.j] = int8(turn)
+			}
+			game.Move(move)
+			if game.board != reference {
+				t.Fatalf("board after %s differs from the reference", move.Notation())
+			}
+
+			// Pass if the opponent cannot move, finish if neither side can
+			over := false
+			if len(naiveMoves(&reference, size, -turn)) > 0 {
+				turn = -turn
+			} else if len(naiveMoves(&reference, size, turn)) == 0 {
+				over = true
+			}
+			if over != (game.winner != 0) {
+				t.Fatalf("game over %v, reference %v", game.winner != 0, over)
+			}
+			if over {
+				if game.winner != game.determineWinner() {
+					t.Fatalf("winner %d, scores %d-%d", game.winner, game.blackScore, game.whiteScore)
+				}
+				break
+			}
+			if game.turn != turn {
+				t.Fatalf("turn %d, reference %d", game.turn, turn)
+			}
+			if !samePositions(game.validSpace, naiveMoves(&reference, size, turn)) {
+				t.Fatalf("valid moves %v, reference %v", game.validSpace, naiveMoves(&reference, size, turn))
+			}
+			black, white := game.getScores()
+			if black != game.blackScore || white != game.whiteScore {
+				t.Fatalf("scores %d-%d, board has %d-%d", game.blackScore, game.whiteScore, black, white)
+			}
+		}
+	})
+}
+
+func naiveMoves(board *[MaxBoardSize][MaxBoardSize]int8, length int, turn int) []Position {
+	moves := []Position{}
+	for i := 0; i < length; i++ {
+		for j := 0; j < length; j++ {
+			if len(naiveFlips(board, length, turn, i, j)) > 0 {
+				moves = append(moves, Position{i, j})
+			}
+		}
+	}
+	return moves
+}
+
+func TestMisereGreed(t *testing.T) {
+	// With equal statistics, selection prefers E6 (one flip) over G4 (three flips)
+	// in the standard game and even more so in the misere variant
+	game := mustParseBoard(t, "--------------------------XOOO------------XO-------------------- X")
+	p := DefaultParams
+	p.InnerWeight, p.CornerWeight, p.BadWeight, p.VeryBadWeight = 0, 0, 0, 0
+	for _, misere := range []bool{false, true} {
+		game.misere = misere
+		root := Node{state: game.compact()}
+		for _, move := range game.validSpace {
+			child := game.Clone()
+			child.Move(move)
+			root.children = append(root.children, &Node{position: move, state: child.compact(), parent: &root, wins: 5, played: 10})
+		}
+		if len(root.children) != 2 {
+			t.Fatalf("%d valid moves, want 2", len(root.children))
+		}
+		if choice := root.selectChild(20, "max", p); choice.position != (Position{5, 4}) {
+			t.Errorf("misere %v: selected %s, want E6", misere, choice.position.Notation())
+		}
+	}
+}
+
+func TestMaxRobust(t *testing.T) {
+	// The most visited move is not the one with the best win rate
+	// maxrobust searches on, for at most half the iterations again,
+	// and reports the fall back to visits if they still disagree
+	setStats := func(root *Node, child *Node, played int, rate float64) {
+		child.played = played
+		child.wins = int(float64(played) * rate)
+		if child.state.turn != root.state.turn {
+			child.wins = played - child.wins
+		}
+	}
+	game, err := StartOptions{Moves: "F5D6C3"}.Board()
+	if err != nil {
+		t.Fatal(err)
+	}
+	tree := func(agree bool) *Node {
+		root := &Node{state: game.compact(), pool: &nodePool{used: 1}}
+		root.expandNode(nil, root.pool)
+		root.played = 1500
+		setStats(root, root.children[0], 1000, 0.4)
+		for _, child := range root.children[1:] {
+			setStats(root, child, 500, 0.3)
+		}
+		if !agree {
+			setStats(root, root.children[len(root.children)-1], 500, 0.6)
+		}
+		return root
+	}
+	if len(tree(true).children) < 2 {
+		t.Fatalf("one move in the tree")
+	}
+
+	p := DefaultParams
+	for _, policy := range []string{"visits", "maxrobust"} {
+		p.FinalPolicy = policy
+		for _, agree := range []bool{true, false} {
+			root := tree(agree)
+			if _, reported := root.finalChild(p); policy == "maxrobust" && reported != map[bool]string{true: "maxrobust", false: "visits"}[agree] {
+				t.Errorf("agree %v: reported %s before the search", agree, reported)
+			}
+			before := root.played
+			move, reported := searchTree(root, 1, 10, time.Time{}, nil, p)
+			iterations := root.played - before
+			if move != root.children[0].position {
+				t.Errorf("%s, agree %v: played %s, want the most visited move", policy, agree, move.Notation())
+			}
+			want, wantPolicy := 10, policy
+			if policy == "maxrobust" && !agree {
+				want, wantPolicy = 16, "visits"
+			}
+			if iterations != want || reported != wantPolicy {
+				t.Errorf("%s, agree %v: %d iterations reported as %s, want %d as %s", policy, agree, iterations, reported, want, wantPolicy)
+			}
+		}
+	}
+}
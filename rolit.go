@@ -0,0 +1,411 @@
+// Rolit: multi-player reversi for 2 to 4 players on the 8x8 board
+// Every player has their own colour (1 to 4) and the four center
+// squares start with one piece of each colour
+// A move must be placed next to a piece already on the board
+// If a player can capture, the move has to capture: pieces of any other
+// colours bracketed between the new piece and a piece of the player's
+// colour are flipped to the player's colour
+// If no capture is possible, any empty square next to a piece can be played
+// The game ends when the board is full and the most pieces win
+
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"math"
+	"math/rand"
+	"net/http"
+	"strings"
+)
+
+const rolitMaxPlayers = 4
+
+// The Rolit board
+type RolitBoard struct {
+	board   [8][8]int                // State of board, colour of the piece (1-4) or 0 for empty
+	players int                      // Number of players, 2 to 4
+	turn    int                      // Whose turn is it (colour 1 to players)
+	scores  [rolitMaxPlayers + 1]int // Number of pieces of each colour, indexed by colour
+	empty   int                      // Number of empty squares left
+	valid   []Position               // Valid moves for the player turn
+}
+
+func newRolitGame(players int) (RolitBoard, error) {
+	// Setup the board for a new game of Rolit
+	// Center pieces are placed clockwise: colour 1 on D4, 2 on E4, 3 on E5, 4 on D5
+	// Colours without a player stay on the board but belong to nobody
+	if players < 2 || players > rolitMaxPlayers {
+		return RolitBoard{}, fmt.Errorf("rolit needs 2 to %d players, got %d", rolitMaxPlayers, players)
+	}
+	B := RolitBoard{players: players, turn: 1}
+	B.board[3][3] = 1
+	B.board[3][4] = 2
+	B.board[4][4] = 3
+	B.board[4][3] = 4
+	B.Setup()
+	return B, nil
+}
+
+func (X *RolitBoard) Setup() {
+	// Initialize scores and valid moves from the board
+	X.scores = [rolitMaxPlayers + 1]int{}
+	X.empty = 0
+	for i := 0; i < 8; i++ {
+		for j := 0; j < 8; j++ {
+			if X.board[i][j] == 0 {
+				X.empty++
+			} else {
+				X.scores[X.board[i][j]]++
+			}
+		}
+	}
+	X.valid = X.getAllValid()
+}
+
+func (X *RolitBoard) captures(space Position, dir Position) int {
+	// Number of pieces captured in direction dir by the player turn placing on space
+	count := 0
+	for {
+		space = Position{space.i + dir.i, space.j + dir.j}
+		if space.i < 0 || space.i > 7 || space.j < 0 || space.j > 7 || X.board[space.i][space.j] == 0 {
+			return 0
+		}
+		if X.board[space.i][space.j] == X.turn {
+			return count
+		}
+		count++
+	}
+}
+
+func (X *RolitBoard) getAllValid() []Position {
+	// Capturing moves if there are any, otherwise all empty squares next to a piece
+	capturing := []Position{}
+	adjacent := []Position{}
+	for i := 0; i < 8; i++ {
+		for j := 0; j < 8; j++ {
+			if X.board[i][j] != 0 {
+				continue
+			}
+			space := Position{i, j}
+			isAdjacent := false
+			for _, dir := range Directions {
+				n := Position{i + dir.i, j + dir.j}
+				if n.i >= 0 && n.i < 8 && n.j >= 0 && n.j < 8 && X.board[n.i][n.j] != 0 {
+					isAdjacent = true
+					break
+				}
+			}
+			if !isAdjacent {
+				continue
+			}
+			adjacent = append(adjacent, space)
+			for _, dir := range Directions {
+				if X.captures(space, dir) > 0 {
+					capturing = append(capturing, space)
+					break
+				}
+			}
+		}
+	}
+	if len(capturing) > 0 {
+		return capturing
+	}
+	return adjacent
+}
+
+func (X *RolitBoard) Move(piece Position) error {
+	// Place a piece of the player turn on the Position given
+	// Flips all captured pieces and passes the turn to the next player
+	if posInSlice(piece, X.valid) == false {
+		return fmt.Errorf("invalid rolit move %s", piece.Notation())
+	}
+	for _, dir := range Directions {
+		count := X.captures(piece, dir)
+		space := piece
+		for k := 0; k < count; k++ {
+			space = Position{space.i + dir.i, space.j + dir.j}
+			X.scores[X.board[space.i][space.j]]--
+			X.board[space.i][space.j] = X.turn
+			X.scores[X.turn]++
+		}
+	}
+	X.board[piece.i][piece.j] = X.turn
+	X.scores[X.turn]++
+	X.empty--
+	X.turn = X.turn%X.players + 1
+	X.valid = X.getAllValid()
+	return nil
+}
+
+func (X *RolitBoard) Finished() bool {
+	return X.empty == 0
+}
+
+func (X *RolitBoard) Rewards() [rolitMaxPlayers + 1]float64 {
+	// Reward of each player (indexed by colour) at the end of the game
+	// The player with most pieces gets 1, tied leaders share it
+	rewards := [rolitMaxPlayers + 1]float64{}
+	best := 0
+	for c := 1; c <= X.players; c++ {
+		if X.scores[c] > best {
+			best = X.scores[c]
+		}
+	}
+	winners := 0
+	for c := 1; c <= X.players; c++ {
+		if X.scores[c] == best {
+			winners++
+		}
+	}
+	for c := 1; c <= X.players; c++ {
+		if X.scores[c] == best {
+			rewards[c] = 1 / float64(winners)
+		}
+	}
+	return rewards
+}
+
+func (X RolitBoard) Show() {
+	// Display board in terminal, colours as 1 to 4
+	for i := 0; i < 8; i++ {
+		for j := 0; j < 8; j++ {
+			if X.board[i][j] == 0 {
+				fmt.Print(" . ")
+			} else {
+				fmt.Printf(" %d ", X.board[i][j])
+			}
+		}
+		fmt.Print("\n\n")
+	}
+}
+
+func rolitRollout(game RolitBoard) [rolitMaxPlayers + 1]float64 {
+	// Simulate random moves until the board is full
+	for !game.Finished() {
+		game.Move(game.valid[rand.Intn(len(game.valid))])
+	}
+	return game.Rewards()
+}
+
+// Node of the multi-player search tree
+// Statistics are kept for every player instead of the two-sided wins/loss of Node
+type RolitNode struct {
+	position Position                     // Position evaluated at node
+	state    RolitBoard                   // State of Board after position is evaluated
+	parent   *RolitNode                   // Parent of node
+	children []*RolitNode                 // Slice of children Nodes
+	played   int                          // No. of times node was visited
+	rewards  [rolitMaxPlayers + 1]float64 // Accumulated reward of each player
+}
+
+func (n *RolitNode) expandNode() {
+	// Create a child for every valid move
+	for _, move := range n.state.valid {
+		gameState := n.state
+		gameState.Move(move)
+		n.children = append(n.children, &RolitNode{
+			position: move,
+			state:    gameState,
+			parent:   n,
+		})
+	}
+}
+
+func (n *RolitNode) selectChild(c float64) *RolitNode {
+	// UCT selection from the point of view of the player to move at n
+	// Each player maximises their own reward (max^n)
+	mover := n.state.turn
+	best := n.children[0]
+	bestScore := math.Inf(-1)
+	for _, child := range n.children {
+		score := math.Inf(1)
+		if child.played > 0 {
+			score = child.rewards[mover]/float64(child.played) +
+				math.Sqrt(c)*math.Sqrt(math.Log(float64(n.played+1))/float64(child.played))
+		}
+		if score > bestScore {
+			best = child
+			bestScore = score
+		}
+	}
+	return best
+}
+
+func (n *RolitNode) backProp(rewards [rolitMaxPlayers + 1]float64, played int) {
+	// Add the reward vector of every player to all nodes up to the root
+	for ; n != nil; n = n.parent {
+		for c := range rewards {
+			n.rewards[c] += rewards[c]
+		}
+		n.played += played
+	}
+}
+
+func RolitSearch(game RolitBoard, nSims int, max_iter int) Position {
+	// Multi-player MCTS for the player to move
+	// Returns the most visited move at the root
+	root := &RolitNode{state: game}
+	for iter := 0; iter < max_iter; iter++ {
+
+		// Select down to a leaf, expand it once it has been visited
+		node := root
+		for len(node.children) > 0 {
+			node = node.selectChild(2)
+		}
+		if node.played > 0 && !node.state.Finished() {
+			node.expandNode()
+			node = node.selectChild(2)
+		}
+
+		// Rollout and backpropagate the rewards of every player
+		rewards := [rolitMaxPlayers + 1]float64{}
+		for k := 0; k < nSims; k++ {
+			r := rolitRollout(node.state)
+			for c := range r {
+				rewards[c] += r[c]
+			}
+		}
+		node.backProp(rewards, nSims)
+	}
+	if len(root.children) == 0 {
+		return game.valid[rand.Intn(len(game.valid))]
+	}
+	best := root.children[0]
+	for _, child := range root.children {
+		if child.played > best.played {
+			best = child
+		}
+	}
+	return best.position
+}
+
+type RolitGameState struct {
+
+	// Struct to hold the Rolit game state posted to the API
+
+	Board   [8][8]int `json:"board"`   // Colour of the piece on each square (1-4) or 0 for empty
+	Players int       `json:"players"` // Number of players, 2 to 4
+	Turn    int       `json:"turn"`    // Colour of the player to move
+}
+
+type RolitResponse struct {
+	Move   [2]int    `json:"move"`   // Decision of agent for Position to place piece
+	Board  [8][8]int `json:"board"`  // Board after the move is made
+	Turn   int       `json:"turn"`   // Whose turn it is after move is made
+	Scores []int     `json:"scores"` // Pieces of each colour after the move, colour 1 first
+}
+
+func RolitAPI(w http.ResponseWriter, r *http.Request) {
+	/*  API Endpoint to search for the agent's move in a game of Rolit
+	Request JSON example:
+		{
+			"board":[[0,0,...],...],        // 8x8 colours of the pieces, 0 for empty
+			"players":4,
+			"turn":2                        // Colour the agent plays as
+		}
+	*/
+	var state = RolitGameState{}
+	body, err := ioutil.ReadAll(io.LimitReader(r.Body, 1048576))
+	if err != nil {
+		panic(err)
+	}
+	if err := json.Unmarshal(body, &state); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if state.Players < 2 || state.Players > rolitMaxPlayers || state.Turn < 1 || state.Turn > state.Players {
+		http.Error(w, "invalid number of players or turn", http.StatusBadRequest)
+		return
+	}
+	game := RolitBoard{board: state.Board, players: state.Players, turn: state.Turn}
+	for i := 0; i < 8; i++ {
+		for j := 0; j < 8; j++ {
+			if game.board[i][j] < 0 || game.board[i][j] > rolitMaxPlayers {
+				http.Error(w, "invalid colour on board", http.StatusBadRequest)
+				return
+			}
+		}
+	}
+	game.Setup()
+	if len(game.valid) == 0 {
+		http.Error(w, "no valid moves", http.StatusBadRequest)
+		return
+	}
+	decision := RolitSearch(game, 10, 300)
+	game.Move(decision)
+
+	response := RolitResponse{
+		Move:   [2]int{decision.i, decision.j},
+		Board:  game.board,
+		Turn:   game.turn,
+		Scores: game.scores[1:],
+	}
+
+	//Allow CORS here By * or specific origin
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+	json.NewEncoder(w).Encode(response)
+}
+
+func rolitCommand(args []string) error {
+	// Play games of Rolit between MCTS and random agents
+	// Example:
+	//     > reversi rolit -games 10 mcts random random random
+	fs := flag.NewFlagSet("rolit", flag.ExitOnError)
+	games := fs.Int("games", 1, "number of games to play")
+	nSims := fs.Int("sims", 10, "number of rollouts per leaf of mcts agents")
+	maxIter := fs.Int("iter", 300, "number of search iterations of mcts agents")
+	verbose := fs.Bool("v", false, "show the board after every game")
+	fs.Parse(args)
+	agents := fs.Args()
+	if len(agents) < 2 || len(agents) > rolitMaxPlayers {
+		return fmt.Errorf("rolit needs 2 to %d agents (mcts or random)", rolitMaxPlayers)
+	}
+	for _, a := range agents {
+		if a != "mcts" && a != "random" {
+			return fmt.Errorf("unknown rolit agent %q, expected mcts or random", a)
+		}
+	}
+	if *games < 1 {
+		return errors.New("games must be at least 1")
+	}
+
+	// Rotate seats every game so every agent plays every colour
+	points := make([]float64, len(agents))
+	for g := 0; g < *games; g++ {
+		game, err := newRolitGame(len(agents))
+		if err != nil {
+			return err
+		}
+		seat := func(colour int) int {
+			return (colour - 1 + g) % len(agents)
+		}
+		for !game.Finished() {
+			move := game.valid[rand.Intn(len(game.valid))]
+			if agents[seat(game.turn)] == "mcts" {
+				move = RolitSearch(game, *nSims, *maxIter)
+			}
+			if err := game.Move(move); err != nil {
+				return err
+			}
+		}
+		if *verbose {
+			game.Show()
+		}
+		rewards := game.Rewards()
+		results := []string{}
+		for c := 1; c <= game.players; c++ {
+			points[seat(c)] += rewards[c]
+			results = append(results, fmt.Sprintf("%s(%d): %d", agents[seat(c)], c, game.scores[c]))
+		}
+		fmt.Printf("Game #%d %s\n", g, strings.Join(results, ", "))
+	}
+	for k, a := range agents {
+		fmt.Printf("Seat %d %-8s %.1f points\n", k+1, a, points[k])
+	}
+	return nil
+}
@@ -0,0 +1,260 @@
+package main
+
+import (
+	"math/rand"
+	"testing"
+)
+
+// Reference Rolit move generator working on the squares only, as naiveFlips for Board
+func naiveRolitFlips(board *[8][8]int, turn int, i int, j int) []Position {
+	flips := []Position{}
+	if board[i][j] != 0 {
+		return flips
+	}
+	for di := -1; di <= 1; di++ {
+		for dj := -1; dj <= 1; dj++ {
+			if di == 0 && dj == 0 {
+				continue
+			}
+			line := []Position{}
+			y, x := i+di, j+dj
+			for y >= 0 && y < 8 && x >= 0 && x < 8 && board[y][x] != 0 && board[y][x] != turn {
+				line = append(line, Position{y, x})
+				y, x = y+di, x+dj
+			}
+			if len(line) > 0 && y >= 0 && y < 8 && x >= 0 && x < 8 && board[y][x] == turn {
+				flips = append(flips, line...)
+			}
+		}
+	}
+	return flips
+}
+
+func naiveRolitMoves(board *[8][8]int, turn int) []Position {
+	capturing := []Position{}
+	adjacent := []Position{}
+	for i := 0; i < 8; i++ {
+		for j := 0; j < 8; j++ {
+			if board[i][j] != 0 {
+				continue
+			}
+			if len(naiveRolitFlips(board, turn, i, j)) > 0 {
+				capturing = append(capturing, Position{i, j})
+			}
+			for di := -1; di <= 1; di++ {
+				for dj := -1; dj <= 1; dj++ {
+					y, x := i+di, j+dj
+					if y >= 0 && y < 8 && x >= 0 && x < 8 && board[y][x] != 0 && !posInSlice(Position{i, j}, adjacent) {
+						adjacent = append(adjacent, Position{i, j})
+					}
+				}
+			}
+		}
+	}
+	if len(capturing) > 0 {
+		return capturing
+	}
+	return adjacent
+}
+
+func naiveRolitPerft(board [8][8]int, players int, turn int, depth int) int {
+	if depth == 0 {
+		return 1
+	}
+	moves := naiveRolitMoves(&board, turn)
+	if len(moves) == 0 {
+		return 1
+	}
+	nodes := 0
+	for _, move := range moves {
+		child := board
+		for _, f := range naiveRolitFlips(&board, turn, move.i, move.j) {
+			child[f.i][f.j] = turn
+		}
+		child[move.i][move.j] = turn
+		nodes += naiveRolitPerft(child, players, turn%players+1, depth-1)
+	}
+	return nodes
+}
+
+func rolitPerft(game RolitBoard, depth int) int {
+	if depth == 0 || game.Finished() {
+		return 1
+	}
+	nodes := 0
+	for _, move := range game.valid {
+		child := game
+		child.Move(move)
+		nodes += rolitPerft(child, depth-1)
+	}
+	return nodes
+}
+
+func TestRolitStart(t *testing.T) {
+	for players := 2; players <= rolitMaxPlayers; players++ {
+		game, err := newRolitGame(players)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for c := 1; c <= rolitMaxPlayers; c++ {
+			if game.scores[c] != 1 {
+				t.Errorf("%d players: colour %d has %d pieces, want 1", players, c, game.scores[c])
+			}
+		}
+		if game.empty != 60 || game.turn != 1 {
+			t.Errorf("%d players: %d empty squares and turn %d, want 60 and 1", players, game.empty, game.turn)
+		}
+		if !samePositions(game.valid, naiveRolitMoves(&game.board, 1)) {
+			t.Errorf("%d players: valid moves %v, reference %v", players, game.valid, naiveRolitMoves(&game.board, 1))
+		}
+	}
+	for _, players := range []int{1, 5} {
+		if _, err := newRolitGame(players); err == nil {
+			t.Errorf("%d players: no error", players)
+		}
+	}
+}
+
+func TestRolitCaptureRule(t *testing.T) {
+	// Captures are forced, any empty square next to a piece is valid without one
+	// Colour 2 on E4 captures D4 from C4, D5 from C6 and E5 from E6
+	game, _ := newRolitGame(4)
+	game.turn = 2
+	game.Setup()
+	if !samePositions(game.valid, []Position{{3, 2}, {5, 2}, {5, 4}}) {
+		t.Errorf("valid moves %v, want C4, C6 and E6", game.valid)
+	}
+
+	lone := RolitBoard{players: 3, turn: 3}
+	lone.board[0][0] = 1
+	lone.board[7][7] = 2
+	lone.Setup()
+	if !samePositions(lone.valid, []Position{{0, 1}, {1, 0}, {1, 1}, {6, 6}, {6, 7}, {7, 6}}) {
+		t.Errorf("valid moves %v, want the squares next to A1 and H8", lone.valid)
+	}
+	if err := lone.Move(Position{3, 3}); err == nil {
+		t.Errorf("move away from all pieces accepted")
+	}
+}
+
+func TestRolitPerft(t *testing.T) {
+	for players := 2; players <= rolitMaxPlayers; players++ {
+		game, _ := newRolitGame(players)
+		for depth := 1; depth <= 3; depth++ {
+			got := rolitPerft(game, depth)
+			want := naiveRolitPerft(game.board, players, 1, depth)
+			if got != want {
+				t.Errorf("%d players: perft(%d) = %d, reference %d", players, depth, got, want)
+			}
+		}
+	}
+}
+
+func TestRolitGameEnd(t *testing.T) {
+	// Games fill the board in 60 moves, the scores add up
+	// and the leaders share a reward of 1
+	r := rand.New(rand.NewSource(1))
+	for g := 0; g < 30; g++ {
+		players := 2 + g%(rolitMaxPlayers-1)
+		game, _ := newRolitGame(players)
+		for moves := 0; moves < 60; moves++ {
+			if game.Finished() {
+				t.Fatalf("finished after %d moves", moves)
+			}
+			if err := game.Move(game.valid[r.Intn(len(game.valid))]); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if !game.Finished() || len(game.valid) != 0 {
+			t.Fatalf("not finished on a full board")
+		}
+		total := 0
+		for c := 1; c <= rolitMaxPlayers; c++ {
+			total += game.scores[c]
+		}
+		if total != 64 {
+			t.Errorf("scores add up to %d", total)
+		}
+		rewards := game.Rewards()
+		sum := 0.0
+		for c := 1; c <= players; c++ {
+			sum += rewards[c]
+			if rewards[c] > 0 && game.scores[c] < game.scores[1] && game.scores[c] < game.scores[2] {
+				t.Errorf("colour %d rewarded with %d pieces", c, game.scores[c])
+			}
+		}
+		if sum < 0.999 || sum > 1.001 {
+			t.Errorf("rewards %v add up to %f", rewards, sum)
+		}
+	}
+
+	tie := RolitBoard{players: 3}
+	tie.scores = [rolitMaxPlayers + 1]int{0, 30, 30, 4, 0}
+	if rewards := tie.Rewards(); rewards[1] != 0.5 || rewards[2] != 0.5 || rewards[3] != 0 {
+		t.Errorf("tie rewards %v, want 0.5 for colours 1 and 2", rewards)
+	}
+}
+
+func TestRolitSearchLegal(t *testing.T) {
+	r := rand.New(rand.NewSource(2))
+	for g := 0; g < 5; g++ {
+		game, _ := newRolitGame(3 + g%2)
+		for plies := r.Intn(50); plies > 0; plies-- {
+			game.Move(game.valid[r.Intn(len(game.valid))])
+		}
+		move := RolitSearch(game, 2, 50)
+		if !posInSlice(move, game.valid) {
+			t.Errorf("search returned %s, not a valid move", move.Notation())
+		}
+	}
+}
+
+func FuzzRolitMoves(f *testing.F) {
+	// Random move sequences checked against the reference move generator
+	// The first byte selects the number of players, each next byte a valid move
+	f.Add([]byte{})
+	f.Add([]byte{2, 0, 1, 2, 3, 4, 5, 6, 7, 8, 9})
+	f.Add([]byte{1, 255, 3, 128, 64, 9, 7, 7, 7, 7})
+	f.Fuzz(func(t *testing.T, data []byte) {
+		players := 4
+		if len(data) > 0 {
+			players = 2 + int(data[0])%(rolitMaxPlayers-1)
+			data = data[1:]
+		}
+		game, _ := newRolitGame(players)
+		reference := game.board
+		for _, b := range data {
+			if game.Finished() {
+				break
+			}
+			turn := game.turn
+			moves := naiveRolitMoves(&reference, turn)
+			if !samePositions(game.valid, moves) {
+				t.Fatalf("valid moves %v, reference %v", game.valid, moves)
+			}
+			move := game.valid[int(b)%len(game.valid)]
+			for _, s := range naiveRolitFlips(&reference, turn, move.i, move.j) {
+				reference[s.i][s.j] = turn
+			}
+			reference[move.i][move.j] = turn
+			game.Move(move)
+			if game.board != reference {
+				t.Fatalf("board after %s differs from the reference", move.Notation())
+			}
+			if game.turn != turn%players+1 {
+				t.Fatalf("turn %d after colour %d", game.turn, turn)
+			}
+			counts := [rolitMaxPlayers + 1]int{}
+			for i := 0; i < 8; i++ {
+				for j := 0; j < 8; j++ {
+					counts[reference[i][j]]++
+				}
+			}
+			empty := counts[0]
+			counts[0] = 0
+			if counts != game.scores || empty != game.empty {
+				t.Fatalf("scores %v with %d empty, board has %v with %d empty", game.scores, game.empty, counts, empty)
+			}
+		}
+	})
+}
@@ -0,0 +1,236 @@
+// Self-play games of the MCTS agent as training data
+// Every position of every game is written as a record with the visit counts
+// of the search at the root and the final result of the game
+// Records are written as JSON lines, optionally with all symmetries
+// of each position to augment the data
+
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"math/rand"
+	"os"
+	"sync"
+	"time"
+)
+
+type SelfPlayRecord struct {
+
+	// Struct to hold a training record of a single position
+	// Results are from the perspective of the side to move
+
+	Game     int      `json:"game"`     // Index of the game
+	Ply      int      `json:"ply"`      // Number of moves played before the position
+	Position string   `json:"position"` // Position string with the side to move
+	Turn     int      `json:"turn"`     // Side to move (1 black, -1 white)
+	Visits   [][3]int `json:"visits"`   // Root visit counts of the moves as [i, j, visits]
+	Move     [2]int   `json:"move"`     // Move played in the game
+	Result   int      `json:"result"`   // Final result: win (1), loss (-1), draw (0)
+	Score    int      `json:"score"`    // Final disc difference
+	Symmetry int      `json:"symmetry"` // Symmetry the position was mapped with, 0 for the game itself
+}
+
+type selfPlayPosition struct {
+	game   Board
+	visits [][3]int
+	move   Position
+}
+
+func selfPlayGame(agent mctsAgent, game Board, explore int) ([]selfPlayPosition, Board) {
+	// Play a game of the agent against itself from game
+	// The first explore moves are drawn in proportion to the visit counts
+	// for more varied games, the agent's choice is played after that
+	positions := []selfPlayPosition{}
+	for ply := 0; game.winner == 0; ply++ {
+		root := Node{
+			state: game.compact(),
+			depth: 0,
+		}
+		move, _ := searchTree(&root, agent.nSims, agent.maxIter, time.Time{}, nil, agent.params)
+		visits := selfPlayVisits(game, &root)
+		total := 0
+		for _, v := range visits {
+			total += v[2]
+		}
+		if ply < explore && total > 0 {
+			r := rand.Intn(total)
+			for _, v := range visits {
+				if r -= v[2]; r < 0 {
+					move = Position{v[0], v[1]}
+					break
+				}
+			}
+		}
+		positions = append(positions, selfPlayPosition{game, visits, move})
+		game.Move(move)
+	}
+	return positions, game
+}
+
+func selfPlayVisits(game Board, root *Node) [][3]int {
+	// Visit counts of every valid move after a search from root
+	// Symmetric moves share one child in the tree, its visits are
+	// split evenly among them so the policy target covers all of them
+	representatives := game.moveRepresentatives()
+	members := map[int][]int{}
+	for k, r := range representatives {
+		members[r] = append(members[r], k)
+	}
+	visits := [][3]int{}
+	for _, child := range root.children {
+		r := 0
+		for k, move := range game.validSpace {
+			if move == child.position {
+				r = k
+			}
+		}
+		share := members[r]
+		for n, k := range share {
+			played := child.played / len(share)
+			if n < child.played%len(share) {
+				played++
+			}
+			move := game.validSpace[k]
+			visits = append(visits, [3]int{move.i, move.j, played})
+		}
+	}
+	return visits
+}
+
+func selfPlayRecords(index int, positions []selfPlayPosition, end Board, augment bool, canonical bool) []SelfPlayRecord {
+	// Training records of a finished game
+	// With augment every distinct symmetry of each position is included,
+	// with canonical only its canonical form
+	records := []SelfPlayRecord{}
+	for ply, pos := range positions {
+		result := 0
+		switch end.winner {
+		case pos.game.turn:
+			result = 1
+		case -pos.game.turn:
+			result = -1
+		}
+		score := (end.blackScore - end.whiteScore) * pos.game.turn
+		syms := []int{0}
+		if augment {
+			syms = []int{0, 1, 2, 3, 4, 5, 6, 7}
+		} else if canonical {
+			_, sym := pos.game.Canonical()
+			syms = []int{sym}
+		}
+		seen := map[string]bool{}
+		for _, sym := range syms {
+			game := pos.game.Transform(sym)
+			position := game.PositionString()
+			if seen[position] {
+				continue
+			}
+			seen[position] = true
+			visits := [][3]int{}
+			for _, v := range pos.visits {
+				t := Position{v[0], v[1]}.transform(game.length, sym)
+				visits = append(visits, [3]int{t.i, t.j, v[2]})
+			}
+			move := pos.move.transform(game.length, sym)
+			records = append(records, SelfPlayRecord{
+				Game:     index,
+				Ply:      ply,
+				Position: position,
+				Turn:     game.turn,
+				Visits:   visits,
+				Move:     [2]int{move.i, move.j},
+				Result:   result,
+				Score:    score,
+				Symmetry: sym,
+			})
+		}
+	}
+	return records
+}
+
+func selfPlayCommand(args []string) error {
+	// Play self-play games and write the training records as JSON lines
+	// Example:
+	//     > reversi selfplay -games 100 -agent mcts:20:300 -explore 10 -augment -out selfplay.jsonl
+	fs := flag.NewFlagSet("selfplay", flag.ExitOnError)
+	games := fs.Int("games", 10, "number of games to play")
+	spec := fs.String("agent", "mcts:20:300", "MCTS agent specification, as for tournament")
+	explore := fs.Int("explore", 10, "number of moves at the start of each game drawn in proportion to the visit counts")
+	augment := fs.Bool("augment", false, "also write all symmetries of every position")
+	canonical := fs.Bool("canonical", false, "write every position in its canonical form (ignored with -augment)")
+	out := fs.String("out", "selfplay.jsonl", "file to write the records to")
+	concurrency := fs.Int("concurrency", 1, "number of games played at the same time")
+	start := StartOptions{}
+	start.addFlags(fs)
+	fs.Parse(args)
+
+	a, err := ParseAgent(*spec)
+	if err != nil {
+		return err
+	}
+	agent, ok := a.(mctsAgent)
+	if !ok {
+		return errors.New("self-play needs an mcts agent")
+	}
+	if *concurrency < 1 {
+		*concurrency = 1
+	}
+	f, err := os.Create(*out)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	w := bufio.NewWriter(f)
+	enc := json.NewEncoder(w)
+
+	// Games are played by a pool of workers
+	// Records are written as soon as a game is finished
+	jobs := make(chan int)
+	errs := make(chan error, *games)
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	written := 0
+	for k := 0; k < *concurrency; k++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for index := range jobs {
+				game, err := start.Board()
+				if err != nil {
+					errs <- err
+					continue
+				}
+				positions, end := selfPlayGame(agent, game, *explore)
+				records := selfPlayRecords(index, positions, end, *augment, *canonical)
+				mu.Lock()
+				for _, r := range records {
+					if err := enc.Encode(r); err != nil {
+						errs <- err
+						break
+					}
+				}
+				written += len(records)
+				fmt.Printf("Game %d: %d moves, %d-%d, %d records\n", index, len(positions), end.blackScore, end.whiteScore, len(records))
+				mu.Unlock()
+			}
+		}()
+	}
+	for index := 0; index < *games; index++ {
+		jobs <- index
+	}
+	close(jobs)
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		return err
+	}
+	if err := w.Flush(); err != nil {
+		return err
+	}
+	fmt.Printf("Wrote %d records to %s\n", written, *out)
+	return nil
+}
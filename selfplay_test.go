@@ -0,0 +1,93 @@
+package main
+
+import (
+	"testing"
+)
+
+func TestSelfPlayVisits(t *testing.T) {
+	// The four first moves share one child, its visits are split among them
+	game := newGame()
+	root := Node{state: game.compact()}
+	root.expandNode(nil, &nodePool{})
+	if len(root.children) != 1 {
+		t.Fatalf("%d children on the start position", len(root.children))
+	}
+	root.children[0].played = 10
+	visits := selfPlayVisits(game, &root)
+	if len(visits) != len(game.validSpace) {
+		t.Fatalf("visits %v for %d moves", visits, len(game.validSpace))
+	}
+	total := 0
+	for _, v := range visits {
+		if !posInSlice(Position{v[0], v[1]}, game.validSpace) {
+			t.Errorf("visits for %v, not a valid move", v)
+		}
+		if v[2] != 2 && v[2] != 3 {
+			t.Errorf("%d visits for %v, want 2 or 3", v[2], v)
+		}
+		total += v[2]
+	}
+	if total != 10 {
+		t.Errorf("%d visits in all, want 10", total)
+	}
+}
+
+func TestSelfPlayRecords(t *testing.T) {
+	// Every symmetry of a position is a record of its own, with the visits
+	// and the move mapped along, and the canonical form is the same for all
+	game := newGame()
+	if err := game.playMoves([]Position{{4, 5}, {5, 3}, {2, 2}}); err != nil {
+		t.Fatal(err)
+	}
+	visits := [][3]int{}
+	for k, move := range game.validSpace {
+		visits = append(visits, [3]int{move.i, move.j, k + 1})
+	}
+	move := game.validSpace[0]
+	end := Board{winner: 1, blackScore: 40, whiteScore: 24}
+	positions := []selfPlayPosition{{game, visits, move}}
+
+	records := selfPlayRecords(3, positions, end, true, false)
+	if len(records) != symmetries {
+		t.Fatalf("%d records, want %d", len(records), symmetries)
+	}
+	played := game
+	played.Move(move)
+	canonical := ""
+	for _, record := range records {
+		if record.Game != 3 || record.Ply != 0 || record.Result != -1 || record.Score != -16 || record.Turn != -1 {
+			t.Errorf("symmetry %d: record %+v", record.Symmetry, record)
+		}
+		board := mustParseBoard(t, record.Position)
+		if board.PositionString() != game.Transform(record.Symmetry).PositionString() {
+			t.Errorf("symmetry %d: position %s", record.Symmetry, record.Position)
+		}
+		total := 0
+		for _, v := range record.Visits {
+			if !posInSlice(Position{v[0], v[1]}, board.validSpace) {
+				t.Errorf("symmetry %d: visits for %v, not a valid move", record.Symmetry, v)
+			}
+			total += v[2]
+		}
+		if want := len(visits) * (len(visits) + 1) / 2; total != want {
+			t.Errorf("symmetry %d: %d visits, want %d", record.Symmetry, total, want)
+		}
+		board.Move(Position{record.Move[0], record.Move[1]})
+		if board.PositionString() != played.Transform(record.Symmetry).PositionString() {
+			t.Errorf("symmetry %d: move %v does not lead to the position played", record.Symmetry, record.Move)
+		}
+
+		// The canonical record of every symmetry is the same
+		mapped := []selfPlayPosition{{game.Transform(record.Symmetry), record.Visits, Position{record.Move[0], record.Move[1]}}}
+		position := selfPlayRecords(0, mapped, end, false, true)[0].Position
+		if canonical == "" {
+			canonical = position
+		} else if position != canonical {
+			t.Errorf("symmetry %d: canonical %s, want %s", record.Symmetry, position, canonical)
+		}
+	}
+
+	if records := selfPlayRecords(0, positions, end, false, false); len(records) != 1 || records[0].Symmetry != 0 || records[0].Position != game.PositionString() {
+		t.Errorf("records without augmentation %+v", records)
+	}
+}
@@ -0,0 +1,322 @@
+// Start positions for new games
+// Standard (cross) and parallel openings, arbitrary setup positions,
+// positions reached by the moves of a game file,
+// handicap games where the weaker side starts with corner pieces
+// and boards with blocked squares
+
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+type StartOptions struct {
+
+	// Struct to hold how a new game is set up
+	// Used by the API, the command line and tournaments
+
+	BoardSize      int      `json:"boardSize"`      // Length of the board, 8 if not given
+	Start          string   `json:"start"`          // Center pieces, "cross" (default) or "parallel"
+	Position       string   `json:"position"`       // Setup position string, overrides boardSize and start
+	Handicap       int      `json:"handicap"`       // Number of corners (0-4) given to the weaker side
+	HandicapColour int      `json:"handicapColour"` // Colour of the weaker side (1 black, -1 white), black if not given
+	Variant        string   `json:"variant"`        // Rule variant, "standard" (default) or "misere"
+	Blocked        [][2]int `json:"blocked"`        // Blocked squares
+	Obstacles      int      `json:"obstacles"`      // Number of randomly placed blocked squares
+	Moves          string   `json:"moves"`          // Moves played from the setup, e.g. "F5D6C3"
+	Plies          int      `json:"plies"`          // Number of the moves played, all if 0
+	GameFile       string   `json:"-"`              // Game file to take the moves from, command line only
+	GameIndex      int      `json:"-"`              // Game of the game file, from 0
+}
+
+// Order in which handicap corners are given: A1, H8, H1, A8 on an 8x8 board
+func handicapCorners(length int) []Position {
+	last := length - 1
+	return []Position{{0, 0}, {last, last}, {0, last}, {last, 0}}
+}
+
+func newGameStart(size int, start string) (Board, error) {
+	// Setup the board for a new game with the given center pieces
+	// cross:    the standard start, same colours on the diagonals
+	// parallel: same colours side by side, white on top
+	if err := validBoardSize(size); err != nil {
+		return Board{}, err
+	}
+	switch start {
+	case "", "cross":
+		return newGameSize(size), nil
+	case "parallel":
+		c := size / 2
+		Grid := [MaxBoardSize][MaxBoardSize]int8{}
+		Grid[c-1][c-1] = -1
+		Grid[c-1][c] = -1
+		Grid[c][c-1] = 1
+		Grid[c][c] = 1
+		B := Board{
+			length: size,
+			board:  Grid,
+			turn:   1,
+		}
+		B.Setup()
+		return B, nil
+	}
+	return Board{}, fmt.Errorf("unknown start %q, expected cross or parallel", start)
+}
+
+func (X *Board) addHandicap(corners int, colour int) error {
+	// Place pieces of colour on the first corners of handicapCorners
+	// The corners have to be empty
+	if corners < 0 || corners > 4 {
+		return fmt.Errorf("handicap must be from 0 to 4 corners, got %d", corners)
+	}
+	if colour != 1 && colour != -1 {
+		return fmt.Errorf("handicap colour must be 1 (black) or -1 (white), got %d", colour)
+	}
+	for _, corner := range handicapCorners(X.length)[:corners] {
+		if X.board[corner.i][corner.j] != 0 {
+			return fmt.Errorf("handicap corner %s is not empty", corner.Notation())
+		}
+		X.board[corner.i][corner.j] = int8(colour)
+	}
+	X.Setup()
+	return nil
+}
+
+func (X *Board) addBlocked(blocked []Position) error {
+	// Block the given squares, which have to be empty
+	for _, space := range blocked {
+		if !X.inRange(space) {
+			return fmt.Errorf("cannot block square %v, it is outside the %dx%d board", [2]int{space.i, space.j}, X.length, X.length)
+		}
+		if X.board[space.i][space.j] != 0 {
+			return fmt.Errorf("cannot block square %s, it is not empty", space.Notation())
+		}
+		X.board[space.i][space.j] = blockedSquare
+	}
+	X.Setup()
+	return nil
+}
+
+func (X *Board) addObstacles(n int) error {
+	// Block n random empty squares
+	// Layouts leaving the side to move without valid moves are drawn again
+	if n < 0 || n > len(X.empty) {
+		return fmt.Errorf("cannot place %d obstacles on %d empty squares", n, len(X.empty))
+	}
+	for attempt := 0; attempt < 100; attempt++ {
+		B := *X
+		empty := append([]Position{}, X.empty...)
+		rand.Shuffle(len(empty), func(a, b int) {
+			empty[a], empty[b] = empty[b], empty[a]
+		})
+		B.addBlocked(empty[:n])
+		if len(B.validSpace) > 0 {
+			*X = B
+			return nil
+		}
+	}
+	return fmt.Errorf("no layout of %d obstacles leaves a valid move", n)
+}
+
+func readGameFile(path string, index int) ([]Position, error) {
+	// Moves of the game at index (from 0) in a game file, see readGames
+	if index < 0 {
+		return nil, fmt.Errorf("game index must not be negative, got %d", index)
+	}
+	games, err := readGames(path)
+	if err != nil {
+		return nil, err
+	}
+	if index >= len(games) {
+		return nil, fmt.Errorf("%s has %d games, no game %d", path, len(games), index)
+	}
+	return games[index], nil
+}
+
+func readGames(path string) ([][]Position, error) {
+	// Moves of all games in a game file
+	// Either a WTHOR database (.wtb) or a move list
+	// with one game per line, as in opening suites
+	if !strings.EqualFold(filepath.Ext(path), ".wtb") {
+		return readOpenings(path)
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	wr, err := NewWthorReader(f)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", path, err)
+	}
+	games := [][]Position{}
+	for {
+		g, err := wr.Next()
+		if err == io.EOF {
+			return games, nil
+		}
+		if err != nil {
+			return nil, fmt.Errorf("%s: %v", path, err)
+		}
+		games = append(games, g.Moves)
+	}
+}
+
+func (X *Board) playMoves(moves []Position) error {
+	// Play a move sequence, passes are made by Board.Move
+	// Returns an error at the first illegal move
+	for k, move := range moves {
+		if posInSlice(move, X.validSpace) == false {
+			return fmt.Errorf("illegal move %s at move %d", move.Notation(), k+1)
+		}
+		X.Move(move)
+	}
+	return nil
+}
+
+func (o StartOptions) moves() ([]Position, error) {
+	// Moves to play from the setup, from Moves or from the game file
+	// and cut to Plies
+	var moves []Position
+	var err error
+	if o.GameFile != "" {
+		moves, err = readGameFile(o.GameFile, o.GameIndex)
+	} else {
+		moves, err = parseMoves(o.Moves)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if o.Plies < 0 {
+		return nil, fmt.Errorf("plies must not be negative, got %d", o.Plies)
+	}
+	if o.Plies > 0 && o.Plies < len(moves) {
+		moves = moves[:o.Plies]
+	}
+	return moves, nil
+}
+
+func (X Board) GameState() GameState {
+	// Convert a Board to the game state used by the API
+	state := GameState{
+		BlackFilled: [][2]int{},
+		WhiteFilled: [][2]int{},
+		Blocked:     [][2]int{},
+		Turn:        X.turn,
+		BoardSize:   X.length,
+		Variant:     "standard",
+		Position:    X.PositionString(),
+	}
+	if X.misere {
+		state.Variant = "misere"
+	}
+	for i := 0; i < X.length; i++ {
+		for j := 0; j < X.length; j++ {
+			if X.board[i][j] == 1 {
+				state.BlackFilled = append(state.BlackFilled, [2]int{i, j})
+			}
+			if X.board[i][j] == -1 {
+				state.WhiteFilled = append(state.WhiteFilled, [2]int{i, j})
+			}
+			if X.board[i][j] == blockedSquare {
+				state.Blocked = append(state.Blocked, [2]int{i, j})
+			}
+		}
+	}
+	return state
+}
+
+func (o StartOptions) Board() (Board, error) {
+	// Setup the start position of a new game
+	game := Board{}
+	var err error
+	if o.Position != "" {
+		game, err = ParseBoard(o.Position)
+	} else {
+		size := o.BoardSize
+		if size == 0 {
+			size = 8
+		}
+		game, err = newGameStart(size, o.Start)
+	}
+	if err != nil {
+		return game, err
+	}
+	if err := game.setVariant(o.Variant); err != nil {
+		return game, err
+	}
+	colour := o.HandicapColour
+	if colour == 0 {
+		colour = 1
+	}
+	if err := game.addHandicap(o.Handicap, colour); err != nil {
+		return game, err
+	}
+	blocked := []Position{}
+	for _, b := range o.Blocked {
+		blocked = append(blocked, Position{b[0], b[1]})
+	}
+	if err := game.addBlocked(blocked); err != nil {
+		return game, err
+	}
+	moves, err := o.moves()
+	if err != nil {
+		return game, err
+	}
+	if err := game.playMoves(moves); err != nil {
+		return game, err
+	}
+	if err := game.addObstacles(o.Obstacles); err != nil {
+		return game, err
+	}
+	if len(game.validSpace) == 0 {
+		return game, fmt.Errorf("no valid moves for the side to move in the start position")
+	}
+	return game, nil
+}
+
+func (o *StartOptions) addFlags(fs *flag.FlagSet) {
+	// Command line flags to set up the start position
+	fs.IntVar(&o.BoardSize, "size", 8, "board size")
+	fs.StringVar(&o.Start, "start", "cross", "center pieces of the start position: cross or parallel")
+	fs.StringVar(&o.Position, "setup", "", "setup position string to start from, overrides -size and -start")
+	fs.IntVar(&o.Handicap, "handicap", 0, "number of corners (0-4) given to the weaker side")
+	fs.IntVar(&o.HandicapColour, "handicap-colour", 1, "colour of the weaker side (1 black, -1 white)")
+	fs.StringVar(&o.Variant, "variant", "standard", "rule variant: standard or misere")
+	fs.Func("blocked", "blocked squares in move notation, e.g. \"D1 E8\"", func(s string) error {
+		moves, err := parseMoves(s)
+		for _, m := range moves {
+			o.Blocked = append(o.Blocked, [2]int{m.i, m.j})
+		}
+		return err
+	})
+	fs.IntVar(&o.Obstacles, "obstacles", 0, "number of randomly placed blocked squares")
+	fs.StringVar(&o.Moves, "moves", "", "moves played from the setup, e.g. \"F5D6C3\"")
+	fs.StringVar(&o.GameFile, "game", "", "game file to take the moves from, a WTHOR .wtb file or one move list per line")
+	fs.IntVar(&o.GameIndex, "game-index", 0, "game of the game file, from 0")
+	fs.IntVar(&o.Plies, "plies", 0, "number of the moves played, all if 0")
+}
+
+func newGameCommand(args []string) error {
+	// Print the start position of a new game as a position string
+	// Example:
+	//     > reversi newgame -start parallel -handicap 2
+	//     > reversi newgame -game WTH_2019.wtb -game-index 12 -plies 20
+	fs := flag.NewFlagSet("newgame", flag.ExitOnError)
+	o := StartOptions{}
+	o.addFlags(fs)
+	fs.Parse(args)
+	game, err := o.Board()
+	if err != nil {
+		return err
+	}
+	game.Show()
+	fmt.Println(game.PositionString())
+	return nil
+}
@@ -0,0 +1,71 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestStartMoves(t *testing.T) {
+	game, err := StartOptions{Moves: "F5D6C3D3C4", Plies: 3}.Board()
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := newGame()
+	want.playMoves([]Position{{4, 5}, {5, 3}, {2, 2}})
+	if game.PositionString() != want.PositionString() {
+		t.Errorf("position %s, want %s", game.PositionString(), want.PositionString())
+	}
+	for _, moves := range []string{"F5F5", "A1", "F5Z9"} {
+		if _, err := (StartOptions{Moves: moves}).Board(); err == nil {
+			t.Errorf("moves %s: no error", moves)
+		}
+	}
+}
+
+func TestStartGameFile(t *testing.T) {
+	// The same game as a move list and as a WTHOR database
+	dir := t.TempDir()
+	list := filepath.Join(dir, "games.txt")
+	if err := os.WriteFile(list, []byte("# games\nC4C3\n\nF5 D6 C3 D3\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	wtb := make([]byte, wthorHeaderSize+2*wthorGameSize)
+	wtb[4] = 2
+	copy(wtb[wthorHeaderSize+8:], []byte{43, 33})
+	copy(wtb[wthorHeaderSize+wthorGameSize+8:], []byte{56, 64, 33, 34})
+	database := filepath.Join(dir, "WTH_2019.wtb")
+	if err := os.WriteFile(database, wtb, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	want := newGame()
+	want.playMoves([]Position{{4, 5}, {5, 3}, {2, 2}})
+	for _, path := range []string{list, database} {
+		game, err := StartOptions{GameFile: path, GameIndex: 1, Plies: 3}.Board()
+		if err != nil {
+			t.Fatalf("%s: %v", path, err)
+		}
+		if game.PositionString() != want.PositionString() {
+			t.Errorf("%s: position %s, want %s", path, game.PositionString(), want.PositionString())
+		}
+		if _, err := (StartOptions{GameFile: path, GameIndex: 2}).Board(); err == nil {
+			t.Errorf("%s: no error for a missing game", path)
+		}
+	}
+}
+
+func TestStartBlocked(t *testing.T) {
+	game, err := StartOptions{Blocked: [][2]int{{0, 0}, {7, 7}}}.Board()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if game.board[0][0] != blockedSquare || game.board[7][7] != blockedSquare {
+		t.Errorf("A1 and H8 not blocked")
+	}
+	for _, blocked := range [][2]int{{0, 99}, {-1, 0}, {8, 3}, {3, 3}} {
+		if _, err := (StartOptions{Blocked: [][2]int{blocked}}).Board(); err == nil {
+			t.Errorf("blocked square %v: no error", blocked)
+		}
+	}
+}
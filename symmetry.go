@@ -0,0 +1,124 @@
+// Symmetries of the board
+// A square board has 8 symmetries: 4 rotations, each optionally mirrored
+// Positions that map onto each other have the same value and moves
+
+package main
+
+// Number of symmetries of a square board
+const symmetries = 8
+
+func (position Position) transform(size int, sym int) Position {
+	// Map a position with symmetry sym (0-7) of a size x size board
+	// Bit 2 mirrors the columns, bits 0-1 rotate by 90 degrees clockwise
+	// Symmetry 0 is the identity
+	i, j := position.i, position.j
+	if sym&4 != 0 {
+		j = size - 1 - j
+	}
+	for r := 0; r < sym&3; r++ {
+		i, j = j, size-1-i
+	}
+	return Position{i, j}
+}
+
+func inverseSymmetry(sym int) int {
+	// Symmetry that maps positions back after sym
+	// Mirroring is its own inverse, rotations are undone by the opposite rotation
+	if sym&4 != 0 {
+		return sym
+	}
+	return (4 - sym) & 3
+}
+
+func (X Board) Transform(sym int) Board {
+	// The board mapped with symmetry sym, with the same side to move and variant
+	B := Board{
+		length: X.length,
+		board:  X.transformGrid(sym),
+		turn:   X.turn,
+		misere: X.misere,
+	}
+	B.Setup()
+	return B
+}
+
+func (X Board) transformGrid(sym int) [MaxBoardSize][MaxBoardSize]int8 {
+	// Only the squares of the board mapped with symmetry sym
+	Grid := [MaxBoardSize][MaxBoardSize]int8{}
+	for i := 0; i < X.length; i++ {
+		for j := 0; j < X.length; j++ {
+			t := Position{i, j}.transform(X.length, sym)
+			Grid[t.i][t.j] = X.board[i][j]
+		}
+	}
+	return Grid
+}
+
+func (X Board) Canonical() (Board, int) {
+	// Canonical form of the board, the same for all its symmetries,
+	// and the symmetry that maps the board onto it
+	// The canonical form is the symmetry with the smallest squares
+	// in row order, so symmetric positions share one representative
+	best, _ := X.canonicalGrid()
+	return X.Transform(best), best
+}
+
+func (X Board) canonicalGrid() (int, [MaxBoardSize][MaxBoardSize]int8) {
+	// Symmetry and squares of the canonical form, without setting up its Board
+	best := 0
+	bestGrid := X.board
+	for sym := 1; sym < symmetries; sym++ {
+		Grid := X.transformGrid(sym)
+		if compareGrids(Grid, bestGrid, X.length) < 0 {
+			best, bestGrid = sym, Grid
+		}
+	}
+	return best, bestGrid
+}
+
+func compareGrids(a [MaxBoardSize][MaxBoardSize]int8, b [MaxBoardSize][MaxBoardSize]int8, length int) int {
+	for i := 0; i < length; i++ {
+		for j := 0; j < length; j++ {
+			if a[i][j] != b[i][j] {
+				if a[i][j] < b[i][j] {
+					return -1
+				}
+				return 1
+			}
+		}
+	}
+	return 0
+}
+
+func (X Board) Symmetries() []int {
+	// Symmetries other than the identity that map the board onto itself
+	// e.g. the start position is unchanged by the diagonal mirrors
+	syms := []int{}
+	for sym := 1; sym < symmetries; sym++ {
+		if compareGrids(X.transformGrid(sym), X.board, X.length) == 0 {
+			syms = append(syms, sym)
+		}
+	}
+	return syms
+}
+
+func (X Board) moveRepresentatives() []int {
+	// Representative of every valid move, as an index in X.validSpace
+	// Moves mapped onto each other by a symmetry of the board lead to
+	// symmetric positions, so all but the first of them are redundant
+	// On the start position this leaves 1 of the 4 first moves
+	representative := make([]int, len(X.validSpace))
+	syms := X.Symmetries()
+	for k, move := range X.validSpace {
+		representative[k] = k
+		for _, sym := range syms {
+			t := move.transform(X.length, sym)
+			for l := 0; l < k; l++ {
+				if X.validSpace[l] == t && representative[l] < representative[k] {
+					representative[k] = representative[l]
+				}
+			}
+		}
+	}
+	return representative
+}
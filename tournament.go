@@ -0,0 +1,476 @@
+// Tournament runner to pit agents against each other
+// Generalizes Simulator to any number of configurable agents
+
+package main
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"math/rand"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// An agent that decides on the move to make for the player to move
+type Agent interface {
+	Name() string
+	SelectMove(game Board) Position
+}
+
+// MCTS agent using Search
+type mctsAgent struct {
+	name    string
+	nSims   int    // Number of rollouts per leaf
+	maxIter int    // Number of search iterations
+	params  Params // Parameters of the search heuristics
+}
+
+func (a mctsAgent) Name() string {
+	return a.name
+}
+
+func (a mctsAgent) SelectMove(game Board) Position {
+	root := Node{
+		state: game.compact(),
+		depth: 0,
+	}
+	return SearchWith(root, a.nSims, a.maxIter, a.params)
+}
+
+// Random agent, optionally avoiding very bad positions like simRandPlus
+type randomAgent struct {
+	name     string
+	semiRand bool // Choose again when a very bad position is chosen
+}
+
+func (a randomAgent) Name() string {
+	return a.name
+}
+
+func (a randomAgent) SelectMove(game Board) Position {
+	move := game.validSpace[rand.Intn(len(game.validSpace))]
+	if a.semiRand {
+
+		// When a very bad position is chosen,
+		// Choose again, repeat again if very bad position chosen
+		if posInSlice(move, veryBadPositions(game.length)) == true {
+			move = game.validSpace[rand.Intn(len(game.validSpace))]
+			if posInSlice(move, veryBadPositions(game.length)) == true {
+				move = game.validSpace[rand.Intn(len(game.validSpace))]
+			}
+		}
+	}
+	return move
+}
+
+func ParseAgent(spec string) (Agent, error) {
+	// Create an Agent from a command line specification
+	// An optional name can be given before "="
+	// Example:
+	//     "mcts:20:300"               MCTS agent with nSims 20, max_iter 300
+	//     "strong=mcts:50:600"        Same, named "strong"
+	//     "mcts:20:300:exploration=2" With parameters other than DefaultParams
+	//     "mcts:20:300:policy=visits" With a final move policy other than heuristic
+	//     "mcts:20:300:puct=1.5"      PUCT selection with the heuristic prior
+	//     "mcts:20:300:network=net.json:valueWeight=1:puct=1.5:prior=network"
+	//                                 Network for leaf evaluation and as prior
+	//     "random"                    Random play
+	//     "randplus"                  Random play avoiding very bad positions
+	name := spec
+	if k := strings.Index(spec, "="); k >= 0 && !strings.Contains(spec[:k], ":") {
+		name, spec = spec[:k], spec[k+1:]
+	}
+	fields := strings.Split(spec, ":")
+	switch fields[0] {
+	case "mcts":
+		a := mctsAgent{name: name, nSims: 20, maxIter: 300, params: DefaultParams}
+		var err error
+
+		// Parameters are given as name=value after nSims and max_iter
+		for len(fields) > 3 {
+			kv := strings.SplitN(fields[len(fields)-1], "=", 2)
+			if len(kv) != 2 {
+				return nil, fmt.Errorf("invalid mcts agent %q, expected mcts[:nSims[:max_iter[:name=value...]]]", spec)
+			}
+			fields = fields[:len(fields)-1]
+			if err := a.params.SetString(kv[0], kv[1]); err != nil {
+				return nil, fmt.Errorf("agent %q: %v", spec, err)
+			}
+		}
+		if err := validParams(a.params); err != nil {
+			return nil, fmt.Errorf("agent %q: %v", spec, err)
+		}
+		if len(fields) > 1 {
+			if a.nSims, err = strconv.Atoi(fields[1]); err != nil || a.nSims < 1 {
+				return nil, fmt.Errorf("invalid nSims in agent %q", spec)
+			}
+		}
+		if len(fields) > 2 {
+			if a.maxIter, err = strconv.Atoi(fields[2]); err != nil || a.maxIter < 0 {
+				return nil, fmt.Errorf("invalid max_iter in agent %q", spec)
+			}
+		}
+		return a, nil
+	case "random":
+		return randomAgent{name: name}, nil
+	case "randplus":
+		return randomAgent{name: name, semiRand: true}, nil
+	}
+	return nil, fmt.Errorf("unknown agent %q", spec)
+}
+
+type TournamentGame struct {
+
+	// Struct to hold the result of a single tournament game
+
+	Game       int      `json:"game"`       // Index of the game in the tournament
+	Black      string   `json:"black"`      // Name of the agent playing black
+	White      string   `json:"white"`      // Name of the agent playing white
+	Opening    string   `json:"opening"`    // Opening moves played before the agents take over
+	Moves      []string `json:"moves"`      // All moves of the game including the opening
+	BlackScore int      `json:"blackScore"` // Black pieces at the end of the game
+	WhiteScore int      `json:"whiteScore"` // White pieces at the end of the game
+	Winner     int      `json:"winner"`     // Black (1), White (-1), Draw (99)
+}
+
+type Standing struct {
+
+	// Struct to hold the overall results of an agent in a tournament
+
+	Agent  string  `json:"agent"`
+	Games  int     `json:"games"`
+	Wins   int     `json:"wins"`
+	Draws  int     `json:"draws"`
+	Losses int     `json:"losses"`
+	Score  float64 `json:"score"` // Fraction of points scored, draws count half
+}
+
+type Tournament struct {
+	Agents      []Agent
+	Gauntlet    bool         // Only the first agent plays against every other agent
+	Rounds      int          // Number of games per pairing, per opening and colour
+	Openings    [][]Position // Opening suite; every opening is played with both colours
+	Concurrency int          // Number of games played at the same time
+	Start       StartOptions // Start position of every game, before the opening
+}
+
+type tournamentPairing struct {
+	black   Agent
+	white   Agent
+	opening []Position
+	start   Board
+}
+
+func (t Tournament) pairings() []tournamentPairing {
+	// All games to be played in the tournament
+	// Each pair of agents plays every opening with both colours
+	openings := t.Openings
+	if len(openings) == 0 {
+		openings = [][]Position{{}}
+	}
+	pairings := []tournamentPairing{}
+	for a := 0; a < len(t.Agents); a++ {
+		for b := a + 1; b < len(t.Agents); b++ {
+			if t.Gauntlet && a != 0 {
+				continue
+			}
+			for r := 0; r < t.Rounds; r++ {
+				for _, opening := range openings {
+					pairings = append(pairings,
+						tournamentPairing{black: t.Agents[a], white: t.Agents[b], opening: opening},
+						tournamentPairing{black: t.Agents[b], white: t.Agents[a], opening: opening},
+					)
+				}
+			}
+		}
+	}
+	return pairings
+}
+
+func playGame(black Agent, white Agent, opening []Position, game Board) (TournamentGame, error) {
+	// Play a single game between two agents from the start position game
+	// The opening moves are played first, then the agents take turns
+	result := TournamentGame{
+		Black: black.Name(),
+		White: white.Name(),
+		Moves: []string{},
+	}
+	for k, move := range opening {
+		if game.winner != 0 || posInSlice(move, game.validSpace) == false {
+			return result, fmt.Errorf("illegal opening move %s at move %d", move.Notation(), k+1)
+		}
+		game.Move(move)
+		result.Opening += move.Notation()
+		result.Moves = append(result.Moves, move.Notation())
+	}
+	for game.winner == 0 {
+		agent := black
+		if game.turn == -1 {
+			agent = white
+		}
+		move := agent.SelectMove(game)
+		if posInSlice(move, game.validSpace) == false {
+			return result, fmt.Errorf("agent %s made illegal move %s", agent.Name(), move.Notation())
+		}
+		game.Move(move)
+		result.Moves = append(result.Moves, move.Notation())
+	}
+	result.BlackScore = game.blackScore
+	result.WhiteScore = game.whiteScore
+	result.Winner = game.winner
+	return result, nil
+}
+
+func (t Tournament) Run(progress func(TournamentGame)) ([]TournamentGame, error) {
+	// Play all games of the tournament, Concurrency games at a time
+	// progress is called after each game finishes, if not nil
+	// Both games of a pair start from the same position
+	// Random obstacles give every pair a different start position
+	pairings := t.pairings()
+	for k := 0; k < len(pairings); k += 2 {
+		start, err := t.Start.Board()
+		if err != nil {
+			return nil, err
+		}
+		pairings[k].start = start
+		pairings[k+1].start = start
+	}
+	results := make([]TournamentGame, len(pairings))
+	errs := make([]error, len(pairings))
+	jobs := make(chan int)
+	concurrency := t.Concurrency
+	if concurrency < 1 {
+		concurrency = 1
+	}
+
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	for w := 0; w < concurrency; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for k := range jobs {
+				p := pairings[k]
+				results[k], errs[k] = playGame(p.black, p.white, p.opening, p.start)
+				results[k].Game = k
+				if progress != nil && errs[k] == nil {
+					mu.Lock()
+					progress(results[k])
+					mu.Unlock()
+				}
+			}
+		}()
+	}
+	for k := range pairings {
+		jobs <- k
+	}
+	close(jobs)
+	wg.Wait()
+
+	for k, err := range errs {
+		if err != nil {
+			return results, fmt.Errorf("game %d: %v", k, err)
+		}
+	}
+	return results, nil
+}
+
+func Standings(agents []Agent, games []TournamentGame) []Standing {
+	// Tally wins, draws and losses for every agent
+	// Sorted by score, best agent first
+	index := map[string]int{}
+	standings := make([]Standing, len(agents))
+	for k, a := range agents {
+		index[a.Name()] = k
+		standings[k].Agent = a.Name()
+	}
+	for _, g := range games {
+		b := &standings[index[g.Black]]
+		w := &standings[index[g.White]]
+		b.Games++
+		w.Games++
+		switch g.Winner {
+		case 1:
+			b.Wins++
+			w.Losses++
+		case -1:
+			w.Wins++
+			b.Losses++
+		default:
+			b.Draws++
+			w.Draws++
+		}
+	}
+	for k := range standings {
+		s := &standings[k]
+		if s.Games > 0 {
+			s.Score = (float64(s.Wins) + 0.5*float64(s.Draws)) / float64(s.Games)
+		}
+	}
+	sort.SliceStable(standings, func(a, b int) bool {
+		return standings[a].Score > standings[b].Score
+	})
+	return standings
+}
+
+func readOpenings(path string) ([][]Position, error) {
+	// Read an opening suite, one move sequence per line
+	// Blank lines and lines starting with # are ignored
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	openings := [][]Position{}
+	scanner := bufio.NewScanner(f)
+	line := 0
+	for scanner.Scan() {
+		line++
+		s := strings.TrimSpace(scanner.Text())
+		if s == "" || strings.HasPrefix(s, "#") {
+			continue
+		}
+		moves, err := parseMoves(s)
+		if err != nil {
+			return nil, fmt.Errorf("%s:%d: %v", path, line, err)
+		}
+		openings = append(openings, moves)
+	}
+	return openings, scanner.Err()
+}
+
+func writeTournamentCSV(path string, games []TournamentGame) error {
+	// Write one row per game, including the full move list
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	w := csv.NewWriter(f)
+	w.Write([]string{"game", "black", "white", "opening", "blackScore", "whiteScore", "winner", "moves"})
+	for _, g := range games {
+		w.Write([]string{
+			strconv.Itoa(g.Game), g.Black, g.White, g.Opening,
+			strconv.Itoa(g.BlackScore), strconv.Itoa(g.WhiteScore), strconv.Itoa(g.Winner),
+			strings.Join(g.Moves, ""),
+		})
+	}
+	w.Flush()
+	return w.Error()
+}
+
+type tournamentReport struct {
+	Agents    []string         `json:"agents"`
+	Mode      string           `json:"mode"`
+	Timestamp string           `json:"timestamp"`
+	Standings []Standing       `json:"standings"`
+	Games     []TournamentGame `json:"games"`
+}
+
+func writeTournamentJSON(path string, report tournamentReport) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	enc := json.NewEncoder(f)
+	enc.SetIndent("", "  ")
+	return enc.Encode(report)
+}
+
+func tournamentCommand(args []string) error {
+	// Run a round robin or gauntlet tournament between agents
+	// Example:
+	//     > reversi tournament -rounds 10 -concurrency 4 -json results.json mcts:20:300 mcts:10:100 randplus
+	fs := flag.NewFlagSet("tournament", flag.ExitOnError)
+	mode := fs.String("mode", "roundrobin", "tournament mode: roundrobin or gauntlet (first agent against the rest)")
+	rounds := fs.Int("rounds", 1, "games per pairing, per opening and colour")
+	openingsPath := fs.String("openings", "", "opening suite file, one move sequence per line")
+	concurrency := fs.Int("concurrency", 1, "number of games played at the same time")
+	csvPath := fs.String("csv", "", "write game results as CSV to this file")
+	jsonPath := fs.String("json", "", "write standings and game results as JSON to this file")
+	start := StartOptions{}
+	start.addFlags(fs)
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "Usage: tournament [flags] agent agent [agent...]")
+		fmt.Fprintln(fs.Output(), "Agents: mcts[:nSims[:max_iter[:param=value...]]], random, randplus, optionally prefixed with name=")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+	if *mode != "roundrobin" && *mode != "gauntlet" {
+		return fmt.Errorf("unknown tournament mode %q", *mode)
+	}
+	if fs.NArg() < 2 {
+		return errors.New("a tournament needs at least two agents")
+	}
+	t := Tournament{
+		Gauntlet:    *mode == "gauntlet",
+		Rounds:      *rounds,
+		Concurrency: *concurrency,
+		Start:       start,
+	}
+	if _, err := t.Start.Board(); err != nil {
+		return err
+	}
+	names := []string{}
+	for _, spec := range fs.Args() {
+		agent, err := ParseAgent(spec)
+		if err != nil {
+			return err
+		}
+		for _, name := range names {
+			if name == agent.Name() {
+				return fmt.Errorf("duplicate agent name %q, name agents with name=spec", name)
+			}
+		}
+		names = append(names, agent.Name())
+		t.Agents = append(t.Agents, agent)
+	}
+	if *openingsPath != "" {
+		var err error
+		if t.Openings, err = readOpenings(*openingsPath); err != nil {
+			return err
+		}
+	}
+
+	games, err := t.Run(func(g TournamentGame) {
+		fmt.Printf("Game #%d %s vs %s: %d-%d\n", g.Game, g.Black, g.White, g.BlackScore, g.WhiteScore)
+	})
+	if err != nil {
+		return err
+	}
+	standings := Standings(t.Agents, games)
+	fmt.Printf("%-20s %6s %6s %6s %6s %7s\n", "Agent", "Games", "Wins", "Draws", "Losses", "Score")
+	for _, s := range standings {
+		fmt.Printf("%-20s %6d %6d %6d %6d %6.1f%%\n", s.Agent, s.Games, s.Wins, s.Draws, s.Losses, 100*s.Score)
+	}
+	fmt.Println()
+	printRatings(Ratings(games))
+
+	if *csvPath != "" {
+		if err := writeTournamentCSV(*csvPath, games); err != nil {
+			return err
+		}
+	}
+	if *jsonPath != "" {
+		report := tournamentReport{
+			Agents:    names,
+			Mode:      *mode,
+			Timestamp: time.Now().UTC().Format("20060102150405"),
+			Standings: standings,
+			Games:     games,
+		}
+		if err := writeTournamentJSON(*jsonPath, report); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,282 @@
+// Memory of the search tree
+// Nodes keep a compact copy of their position instead of a full Board,
+// the Board is rebuilt from it when a node is expanded or evaluated
+// Nodes are allocated in blocks by the pool of their tree, and when the
+// tree grows beyond p.MaxNodes the least visited subtrees are pruned
+// and their nodes reused
+
+package main
+
+import (
+	"sort"
+)
+
+// Nodes allocated at once by a pool
+const nodeBlockSize = 1024
+
+// Fraction of the node limit left after pruning, so pruning is not
+// needed again for a while
+const pruneTarget = 0.75
+
+type nodeState struct {
+
+	// Struct to hold the position of a node in about a quarter of a Board
+	// The fields used by selection are kept as in Board
+
+	rows       [MaxBoardSize]uint32 // 2 bits per square: empty (0), black (1), white (2), blocked (3)
+	length     int
+	turn       int
+	blackScore int
+	whiteScore int
+	winner     int
+	misere     bool
+	moves      int                  // Number of valid moves of the side to move
+	valid      [MaxBoardSize]uint16 // 1 bit per square: valid move of the side to move
+}
+
+func (X Board) compact() nodeState {
+	s := nodeState{
+		length:     X.length,
+		turn:       X.turn,
+		blackScore: X.blackScore,
+		whiteScore: X.whiteScore,
+		winner:     X.winner,
+		misere:     X.misere,
+		moves:      len(X.validSpace),
+	}
+	s.rows = packRows(X.board, X.length)
+	for _, move := range X.validSpace {
+		s.valid[move.i] |= 1 << move.j
+	}
+	return s
+}
+
+func packRows(Grid [MaxBoardSize][MaxBoardSize]int8, length int) [MaxBoardSize]uint32 {
+	// Squares of the grid at 2 bits each, see nodeState
+	rows := [MaxBoardSize]uint32{}
+	for i := 0; i < length; i++ {
+		for j := 0; j < length; j++ {
+			var square uint32
+			switch Grid[i][j] {
+			case 1:
+				square = 1
+			case -1:
+				square = 2
+			case blockedSquare:
+				square = 3
+			}
+			rows[i] |= square << (2 * j)
+		}
+	}
+	return rows
+}
+
+func (X Board) transpositionKey() nodeState {
+	// Key of the position in a transposition table, the same for all
+	// symmetries of the position and all move orders reaching it
+	// Only the squares, side to move and variant are kept
+	_, Grid := X.canonicalGrid()
+	return nodeState{
+		rows:   packRows(Grid, X.length),
+		length: X.length,
+		turn:   X.turn,
+		misere: X.misere,
+	}
+}
+
+func (s nodeState) Board() Board {
+	// Rebuild the Board of the compact position
+	// Gives the same Board as Setup, with the lists in the same order,
+	// but takes the valid moves from the state instead of checking
+	// every neighbour for flips
+	B := Board{
+		length:     s.length,
+		turn:       s.turn,
+		misere:     s.misere,
+		blackScore: s.blackScore,
+		whiteScore: s.whiteScore,
+		winner:     s.winner,
+		filled:     make([]Position, 0, s.blackScore+s.whiteScore),
+		empty:      make([]Position, 0, s.length*s.length-s.blackScore-s.whiteScore),
+		neighbours: []Position{},
+		validSpace: make([]Position, 0, s.moves),
+	}
+	for i := 0; i < s.length; i++ {
+		for j := 0; j < s.length; j++ {
+			switch (s.rows[i] >> (2 * j)) & 3 {
+			case 0:
+				B.empty = append(B.empty, Position{i, j})
+			case 1:
+				B.board[i][j] = 1
+				B.filled = append(B.filled, Position{i, j})
+			case 2:
+				B.board[i][j] = -1
+				B.filled = append(B.filled, Position{i, j})
+			case 3:
+				B.board[i][j] = blockedSquare
+			}
+		}
+	}
+
+	// Neighbours in the order of initNeighbours, marking the squares
+	// already added instead of searching the list
+	added := [MaxBoardSize]uint16{}
+	for _, piece := range B.filled {
+		for _, dir := range Directions {
+			n := Position{piece.i + dir.i, piece.j + dir.j}
+			if !B.inRange(n) || B.board[n.i][n.j] != 0 || added[n.i]&(1<<n.j) != 0 {
+				continue
+			}
+			added[n.i] |= 1 << n.j
+			B.neighbours = append(B.neighbours, n)
+			if s.valid[n.i]&(1<<n.j) != 0 {
+				B.validSpace = append(B.validSpace, n)
+			}
+		}
+	}
+	return B
+}
+
+type nodePool struct {
+
+	// Struct to hold the allocator of the nodes of a tree
+	// Only the root of a tree points to its pool
+
+	free  []*Node            // Nodes of pruned subtrees, reused first
+	block []Node             // Nodes of the last block not handed out yet
+	used  int                // Nodes in the tree
+	table transpositionTable // Statistics shared by the nodes of a position, nil unless p.Transpositions is set
+}
+
+type transposition struct {
+
+	// Struct to hold the statistics of a position summed over all its nodes
+	// Nodes of the same position reached by other move orders or by a
+	// symmetry start from these instead of from nothing
+
+	key    nodeState // Key of the position in the table, see transpositionKey
+	played int       // No. of simulations below any node of the position
+	wins   int       // Wins of the side to move in them
+}
+
+type transpositionTable map[nodeState]*transposition
+
+func (table transpositionTable) lookup(X Board) *transposition {
+	// Entry of the position of X, added if it is not in the table yet
+	key := X.transpositionKey()
+	t := table[key]
+	if t == nil {
+		t = &transposition{key: key}
+		table[key] = t
+	}
+	return t
+}
+
+func (table transpositionTable) retain(root *Node) {
+	// Remove the entries no node in the tree of root refers to,
+	// so the table does not outgrow the tree after pruning
+	kept := map[*transposition]bool{}
+	var collect func(n *Node)
+	collect = func(n *Node) {
+		if n.transposition != nil {
+			kept[n.transposition] = true
+		}
+		for _, child := range n.children {
+			collect(child)
+		}
+	}
+	collect(root)
+	for key, t := range table {
+		if !kept[t] {
+			delete(table, key)
+		}
+	}
+}
+
+func (pool *nodePool) get() *Node {
+	pool.used++
+	if k := len(pool.free); k > 0 {
+		n := pool.free[k-1]
+		pool.free = pool.free[:k-1]
+		return n
+	}
+	if len(pool.block) == 0 {
+		pool.block = make([]Node, nodeBlockSize)
+	}
+	n := &pool.block[0]
+	pool.block = pool.block[1:]
+	return n
+}
+
+func (pool *nodePool) release(n *Node) {
+	// Return n and all nodes below it to the pool
+	for _, child := range n.children {
+		pool.release(child)
+	}
+	*n = Node{}
+	pool.used--
+	pool.free = append(pool.free, n)
+}
+
+func (pool *nodePool) prune(root *Node, limit int) {
+	// Remove the children of the least visited nodes until the tree
+	// has at most pruneTarget of limit nodes
+	// Pruned nodes keep their statistics and are expanded again
+	// if the search comes back to them
+	expanded := []*Node{}
+	var collect func(n *Node)
+	collect = func(n *Node) {
+		for _, child := range n.children {
+			if len(child.children) > 0 {
+				expanded = append(expanded, child)
+				collect(child)
+			}
+		}
+	}
+	collect(root)
+
+	// Deeper nodes first among nodes with the same visits,
+	// so a subtree is not released again after its parent
+	sort.SliceStable(expanded, func(a, b int) bool {
+		if expanded[a].played != expanded[b].played {
+			return expanded[a].played < expanded[b].played
+		}
+		return expanded[a].depth > expanded[b].depth
+	})
+	target := int(float64(limit) * pruneTarget)
+	for _, n := range expanded {
+		if pool.used <= target {
+			break
+		}
+		for _, child := range n.children {
+			pool.release(child)
+		}
+		n.children = nil
+	}
+	if pool.table != nil {
+		pool.table.retain(root)
+	}
+}
+
+func (pool *nodePool) detach(root *Node, child *Node) {
+	// Make child the root of the tree of root, keeping the pool
+	// The other subtrees of root are returned to the pool
+	for _, sibling := range root.children {
+		if sibling != child {
+			pool.release(sibling)
+		}
+	}
+	root.children = nil
+	pool.used--
+	child.parent = nil
+	child.pool = pool
+}
+
+func countNodes(n *Node) int {
+	// Number of nodes in the tree of n
+	count := 1
+	for _, child := range n.children {
+		count += countNodes(child)
+	}
+	return count
+}
@@ -0,0 +1,158 @@
+package main
+
+import (
+	"math/rand"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestNodeStateRoundTrip(t *testing.T) {
+	r := rand.New(rand.NewSource(4))
+	positions := []Board{mustParseBoard(t, "--------------------#-----------OX-------XO-------#------------- X")}
+	for g := 0; g < 30; g++ {
+		positions = append(positions, randomGame(r, 4+2*r.Intn(7), r.Intn(200)))
+	}
+	for _, game := range positions {
+		game.misere = r.Intn(2) == 0
+		restored := game.compact().Board()
+		if restored.PositionString() != game.PositionString() {
+			t.Fatalf("restored %s, want %s", restored.PositionString(), game.PositionString())
+		}
+		if restored.winner != game.winner || restored.misere != game.misere {
+			t.Errorf("%s: winner %d misere %v, want %d %v", game.PositionString(), restored.winner, restored.misere, game.winner, game.misere)
+		}
+		if restored.blackScore != game.blackScore || restored.whiteScore != game.whiteScore {
+			t.Errorf("%s: scores %d-%d, want %d-%d", game.PositionString(), restored.blackScore, restored.whiteScore, game.blackScore, game.whiteScore)
+		}
+		if !samePositions(restored.validSpace, game.validSpace) || game.compact().moves != len(game.validSpace) {
+			t.Errorf("%s: valid moves %v, want %v", game.PositionString(), restored.validSpace, game.validSpace)
+		}
+
+		// The same Board as a full Setup, down to the order of the lists
+		setup := Board{length: game.length, board: game.board, turn: game.turn, misere: game.misere}
+		setup.Setup()
+		setup.winner = game.winner
+		if !reflect.DeepEqual(restored, setup) {
+			t.Errorf("%s: restored %+v, Setup gives %+v", game.PositionString(), restored, setup)
+		}
+	}
+}
+
+func TestMaxNodes(t *testing.T) {
+	// The tree is pruned back below the limit and the pool counts its nodes
+	p := DefaultParams
+	p.MaxNodes = 500
+	root := Node{state: newGame().compact()}
+	move, _ := searchTree(&root, 1, 1500, time.Time{}, nil, p)
+	if !posInSlice(move, newGame().validSpace) {
+		t.Errorf("search returned %s, not a valid move", move.Notation())
+	}
+	nodes := countNodes(&root)
+	if root.pool.used != nodes {
+		t.Errorf("pool counts %d nodes, tree has %d", root.pool.used, nodes)
+	}
+	if nodes > 600 {
+		t.Errorf("%d nodes, limit %v", nodes, p.MaxNodes)
+	}
+	if root.played != 1501 {
+		t.Errorf("root played %d, want 1501", root.played)
+	}
+
+	// The limit is rounded, 0.6 keeps a tree of 1 node
+	p.MaxNodes = 0.6
+	root = Node{state: newGame().compact()}
+	searchTree(&root, 1, 50, time.Time{}, nil, p)
+	if nodes := countNodes(&root); nodes > 10 {
+		t.Errorf("%d nodes with maxNodes 0.6", nodes)
+	}
+}
+
+func TestTranspositionKey(t *testing.T) {
+	// Symmetries and move orders of a position share its key,
+	// the side to move and the variant do not
+	r := rand.New(rand.NewSource(5))
+	for g := 0; g < 20; g++ {
+		game := randomGame(r, 4+2*r.Intn(7), r.Intn(200))
+		key := game.transpositionKey()
+		for sym := 1; sym < symmetries; sym++ {
+			if game.Transform(sym).transpositionKey() != key {
+				t.Errorf("%s: symmetry %d has another key", game.PositionString(), sym)
+			}
+		}
+		other := game
+		other.turn = -game.turn
+		if other.transpositionKey() == key {
+			t.Errorf("%s: same key for the other side to move", game.PositionString())
+		}
+		other = game
+		other.misere = !game.misere
+		if other.transpositionKey() == key {
+			t.Errorf("%s: same key in misere", game.PositionString())
+		}
+	}
+
+	a, b := newGame(), newGame()
+	if err := a.playMoves([]Position{{4, 5}, {5, 5}, {2, 3}, {2, 2}}); err != nil {
+		t.Fatal(err)
+	}
+	if err := b.playMoves([]Position{{2, 3}, {2, 2}, {4, 5}, {5, 5}}); err != nil {
+		t.Fatal(err)
+	}
+	if a.transpositionKey() != b.transpositionKey() {
+		t.Errorf("F5F6D3C3 and D3C3F5F6 have different keys")
+	}
+}
+
+func TestTranspositions(t *testing.T) {
+	// Nodes of the same position share an entry holding at least their
+	// own statistics, and pruning removes the entries of released nodes
+	p := DefaultParams
+	p.Transpositions = 1
+	p.MaxNodes = 1000
+	root := Node{state: newGame().compact()}
+	searchTree(&root, 1, 3000, time.Time{}, nil, p)
+	table := root.pool.table
+	nodes := map[*transposition]int{}
+	var check func(n *Node)
+	check = func(n *Node) {
+		for _, child := range n.children {
+			tr := child.transposition
+			if tr == nil || table[tr.key] != tr {
+				t.Fatalf("node %s has no entry in the table", child.position.Notation())
+			}
+			if tr.key != child.state.Board().transpositionKey() {
+				t.Fatalf("node %s has the entry of another position", child.position.Notation())
+			}
+			if child.played > tr.played {
+				t.Errorf("node %s played %d, entry %d", child.position.Notation(), child.played, tr.played)
+			}
+			nodes[tr]++
+			check(child)
+		}
+	}
+	check(&root)
+	shared := 0
+	for _, count := range nodes {
+		if count > 1 {
+			shared++
+		}
+	}
+	if shared == 0 {
+		t.Errorf("no entry shared by several nodes")
+	}
+
+	root.pool.prune(&root, 200)
+	nodes = map[*transposition]int{}
+	check(&root)
+	if len(table) != len(nodes) {
+		t.Errorf("%d entries for %d positions after pruning", len(table), len(nodes))
+	}
+
+	p.Transpositions = 0
+	root = Node{state: newGame().compact()}
+	searchTree(&root, 1, 100, time.Time{}, nil, p)
+	if root.pool.table != nil || root.children[0].transposition != nil {
+		t.Errorf("table used with transpositions=0")
+	}
+}
@@ -0,0 +1,245 @@
+// Parameter tuning of the search heuristics with SPSA
+// (Simultaneous Perturbation Stochastic Approximation)
+// Each iteration perturbs all parameters at once in a random direction
+// and plays the perturbed agents against each other to estimate the gradient
+
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"math"
+	"math/rand"
+	"os"
+	"strconv"
+	"strings"
+)
+
+type SPSAParam struct {
+	Name  string  `json:"name"`
+	Value float64 `json:"value"` // Current estimate of the best value
+	Min   float64 `json:"min"`
+	Max   float64 `json:"max"`
+	Step  float64 `json:"step"` // Perturbation size at the first iteration
+}
+
+type SPSAState struct {
+
+	// Struct to hold the progress of a tuning run
+	// Written to the checkpoint file after every iteration
+
+	Iteration    int                  `json:"iteration"`    // Iterations completed
+	Iterations   int                  `json:"iterations"`   // Iterations to run in total
+	Pairs        int                  `json:"pairs"`        // Game pairs played per iteration
+	NSims        int                  `json:"nSims"`        // Rollouts per leaf of the agents
+	MaxIter      int                  `json:"maxIter"`      // Search iterations of the agents
+	LearningRate float64              `json:"learningRate"` // a in the gain sequence a / (A + k + 1)^alpha
+	Stability    float64              `json:"stability"`    // A in the gain sequence, usually ~10% of iterations
+	Alpha        float64              `json:"alpha"`        // Decay of the gain sequence
+	Gamma        float64              `json:"gamma"`        // Decay of the perturbation size
+	Base         Params               `json:"base"`         // Values of the parameters not being tuned
+	Params       []SPSAParam          `json:"params"`
+	History      []map[string]float64 `json:"history"` // Parameter values after each iteration
+}
+
+func (s SPSAState) params(values []float64) Params {
+	// Search parameters with the tuned parameters set to values
+	p := s.Base
+	for k, param := range s.Params {
+		p.Set(param.Name, values[k])
+	}
+	return p
+}
+
+func (s SPSAState) Current() Params {
+	// Search parameters with the current estimates of the tuned parameters
+	values := []float64{}
+	for _, param := range s.Params {
+		values = append(values, param.Value)
+	}
+	return s.params(values)
+}
+
+func (s *SPSAState) Step(concurrency int) (float64, error) {
+	// Run a single SPSA iteration
+	// Plays Pairs game pairs between theta + c_k * delta and theta - c_k * delta
+	// and moves every parameter in the direction of the better agent
+	// Returns the result of the iteration in points per game pair for theta+
+	k := float64(s.Iteration)
+	ak := s.LearningRate / math.Pow(s.Stability+k+1, s.Alpha)
+	ck := 1 / math.Pow(k+1, s.Gamma)
+
+	delta := make([]float64, len(s.Params))
+	plus := make([]float64, len(s.Params))
+	minus := make([]float64, len(s.Params))
+	for i, param := range s.Params {
+		delta[i] = 1
+		if rand.Intn(2) == 0 {
+			delta[i] = -1
+		}
+		plus[i] = math.Min(math.Max(param.Value+ck*param.Step*delta[i], param.Min), param.Max)
+		minus[i] = math.Min(math.Max(param.Value-ck*param.Step*delta[i], param.Min), param.Max)
+	}
+
+	t := Tournament{
+		Agents: []Agent{
+			mctsAgent{name: "plus", nSims: s.NSims, maxIter: s.MaxIter, params: s.params(plus)},
+			mctsAgent{name: "minus", nSims: s.NSims, maxIter: s.MaxIter, params: s.params(minus)},
+		},
+		Rounds:      s.Pairs,
+		Concurrency: concurrency,
+	}
+	games, err := t.Run(nil)
+	if err != nil {
+		return 0, err
+	}
+	result := 0.0
+	for _, g := range games {
+		switch {
+		case g.Winner == 99:
+		case (g.Winner == 1) == (g.Black == "plus"):
+			result++
+		default:
+			result--
+		}
+	}
+	result /= float64(s.Pairs)
+
+	// Gradient step, scaled by the step size of each parameter
+	values := map[string]float64{}
+	for i := range s.Params {
+		param := &s.Params[i]
+		param.Value += ak * param.Step * result * delta[i]
+		param.Value = math.Min(math.Max(param.Value, param.Min), param.Max)
+		values[param.Name] = param.Value
+	}
+	s.History = append(s.History, values)
+	s.Iteration++
+	return result, nil
+}
+
+func (s SPSAState) Save(path string) error {
+	// Write the checkpoint atomically so an interrupted write
+	// never leaves a corrupt checkpoint behind
+	b, err := json.MarshalIndent(s, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(path+".tmp", b, 0644); err != nil {
+		return err
+	}
+	return os.Rename(path+".tmp", path)
+}
+
+func LoadSPSAState(path string) (SPSAState, error) {
+	s := SPSAState{}
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return s, err
+	}
+	err = json.Unmarshal(b, &s)
+	return s, err
+}
+
+func agentSpec(nSims int, maxIter int, p Params) string {
+	// Agent specification for the tournament and sprt commands
+	// Only parameters that differ from DefaultParams are included
+	spec := fmt.Sprintf("mcts:%d:%d", nSims, maxIter)
+	for _, f := range paramFields {
+		value, _ := p.Get(f.name)
+		if def, _ := DefaultParams.Get(f.name); value != def {
+			spec += ":" + f.name + "=" + strconv.FormatFloat(value, 'g', 4, 64)
+		}
+	}
+	if p.Network != "" {
+		spec += ":network=" + p.Network
+	}
+	if p.Patterns != "" {
+		spec += ":patterns=" + p.Patterns
+	}
+	if p.Prior != "" {
+		spec += ":prior=" + p.Prior
+	}
+	if p.FinalPolicy != "" {
+		spec += ":policy=" + p.FinalPolicy
+	}
+	return spec
+}
+
+func tuneCommand(args []string) error {
+	// Tune search parameters with SPSA using self-play games
+	// Progress is checkpointed after each iteration and resumed
+	// if the checkpoint file already exists
+	// Example:
+	//     > reversi tune -params exploration,cornerWeight,badWeight -iterations 2000 -checkpoint spsa.json
+	fs := flag.NewFlagSet("tune", flag.ExitOnError)
+	names := fs.String("params", "exploration,innerWeight,cornerWeight,badWeight", "comma separated parameters to tune")
+	checkpoint := fs.String("checkpoint", "spsa.json", "checkpoint file to save progress to and resume from")
+	s := SPSAState{Base: DefaultParams}
+	fs.IntVar(&s.Iterations, "iterations", 1000, "total number of iterations")
+	fs.IntVar(&s.Pairs, "pairs", 1, "game pairs per iteration")
+	fs.IntVar(&s.NSims, "sims", 10, "rollouts per leaf of the agents")
+	fs.IntVar(&s.MaxIter, "iter", 100, "search iterations of the agents")
+	fs.Float64Var(&s.LearningRate, "a", 1, "learning rate")
+	fs.Float64Var(&s.Stability, "A", 0, "stability constant of the learning rate (default 10% of iterations)")
+	fs.Float64Var(&s.Alpha, "alpha", 0.602, "decay of the learning rate")
+	fs.Float64Var(&s.Gamma, "gamma", 0.101, "decay of the perturbation size")
+	concurrency := fs.Int("concurrency", 1, "number of games played at the same time")
+	fs.Parse(args)
+
+	if _, err := os.Stat(*checkpoint); err == nil {
+		resumed, err := LoadSPSAState(*checkpoint)
+		if err != nil {
+			return fmt.Errorf("%s: %v", *checkpoint, err)
+		}
+		fmt.Printf("Resuming from %s at iteration %d\n", *checkpoint, resumed.Iteration)
+
+		// Allow extending a finished run with more iterations
+		fs.Visit(func(f *flag.Flag) {
+			if f.Name == "iterations" {
+				resumed.Iterations = s.Iterations
+			}
+		})
+		s = resumed
+	} else {
+		if s.Pairs < 1 {
+			return errors.New("pairs must be at least 1")
+		}
+		if s.Stability == 0 {
+			s.Stability = 0.1 * float64(s.Iterations)
+		}
+		for _, name := range strings.Split(*names, ",") {
+			f, err := findParamField(strings.TrimSpace(name))
+			if err != nil {
+				return err
+			}
+			value, _ := DefaultParams.Get(f.name)
+			s.Params = append(s.Params, SPSAParam{
+				Name:  f.name,
+				Value: value,
+				Min:   f.min,
+				Max:   f.max,
+				Step:  f.step,
+			})
+		}
+	}
+
+	for s.Iteration < s.Iterations {
+		result, err := s.Step(*concurrency)
+		if err != nil {
+			return err
+		}
+		if err := s.Save(*checkpoint); err != nil {
+			return err
+		}
+		values := []string{}
+		for _, param := range s.Params {
+			values = append(values, fmt.Sprintf("%s=%.4g", param.Name, param.Value))
+		}
+		fmt.Printf("Iteration %d, result %+.2f, %s\n", s.Iteration, result, strings.Join(values, " "))
+	}
+	fmt.Println("Tuned agent:", agentSpec(s.NSims, s.MaxIter, s.Current()))
+	return nil
+}
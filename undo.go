@@ -0,0 +1,78 @@
+// Making and unmaking moves
+// MakeMove plays a move like Move and records what it changed on an undo
+// stack, so UnmakeMove can restore the board without copying it
+// Depth-first searches can then play and take back moves on one Board
+
+package main
+
+import (
+	"errors"
+	"fmt"
+)
+
+type undoMove struct {
+	move       Position   // Piece placed
+	flipped    []Position // Pieces flipped by the move
+	neighbours []Position // Slices replaced by the move
+	validSpace []Position
+	turn       int // Side that made the move
+	winner     int
+	blackScore int
+	whiteScore int
+}
+
+// Moves made with MakeMove, the last one on top
+type UndoStack []undoMove
+
+func (X *Board) MakeMove(piece Position, undo *UndoStack) error {
+	// Play piece and push what it changed on undo
+	if posInSlice(piece, X.validSpace) == false {
+		return fmt.Errorf("invalid move %s", piece.Notation())
+	}
+	u := undoMove{
+		move:       piece,
+		neighbours: X.neighbours,
+		validSpace: X.validSpace,
+		turn:       X.turn,
+		winner:     X.winner,
+		blackScore: X.blackScore,
+		whiteScore: X.whiteScore,
+	}
+	X.play(piece, &u.flipped)
+	*undo = append(*undo, u)
+	return nil
+}
+
+func (X *Board) UnmakeMove(undo *UndoStack) error {
+	// Take back the last move on undo and pop it
+	// The slices replaced by the move are restored as they were,
+	// Move never writes into them
+	if len(*undo) == 0 {
+		return errors.New("no move to take back")
+	}
+	u := (*undo)[len(*undo)-1]
+	*undo = (*undo)[:len(*undo)-1]
+	X.board[u.move.i][u.move.j] = 0
+	for _, f := range u.flipped {
+		X.board[f.i][f.j] = int8(-u.turn)
+	}
+	X.neighbours = u.neighbours
+	X.validSpace = u.validSpace
+	X.turn = u.turn
+	X.winner = u.winner
+	X.blackScore = u.blackScore
+	X.whiteScore = u.whiteScore
+	return nil
+}
+
+func (X Board) Clone() Board {
+	// Copy of the board that shares no memory with it
+	// A plain copy (B := X) shares the backing arrays of the slices
+	// That is safe for Move, MakeMove and UnmakeMove, which only replace
+	// the slices, but not for code that writes into them or appends to them
+	X.filled = append([]Position{}, X.filled...)
+	X.empty = append([]Position{}, X.empty...)
+	X.neighbours = append([]Position{}, X.neighbours...)
+	X.validSpace = append([]Position{}, X.validSpace...)
+	return X
+}
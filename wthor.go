@@ -0,0 +1,339 @@
+// Reader for the WTHOR database of tournament games
+// File format as published by the Fédération Française d'Othello
+// https://www.ffothello.org/informatique/la-base-wthor/
+
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"encoding/csv"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+)
+
+const (
+	wthorHeaderSize     = 16 // Every WTHOR file starts with a 16 byte header
+	wthorGameSize       = 68 // 8 bytes of game information + 60 moves
+	wthorPlayerSize     = 20 // Player names in WTHOR.JOU
+	wthorTournamentSize = 26 // Tournament names in WTHOR.TRN
+)
+
+type WthorHeader struct {
+
+	// Struct to hold the header of a WTHOR file (.wtb, .jou, .trn)
+
+	Created   [3]int // Date the file was created (year, month, day)
+	Games     int    // Number of games in a .wtb file
+	Records   int    // Number of records in a .jou or .trn file
+	Year      int    // Year the games were played
+	BoardSize int    // Size of the board the games were played on
+	Depth     int    // Search depth used for the theoretical scores
+}
+
+type WthorGame struct {
+
+	// Struct to hold a single game record of a .wtb file
+
+	Year             int        // Year the game was played (from file header)
+	Tournament       int        // Index of the tournament in WTHOR.TRN
+	Black            int        // Index of the black player in WTHOR.JOU
+	White            int        // Index of the white player in WTHOR.JOU
+	BlackScore       int        // Number of black pieces at the end of the game
+	TheoreticalScore int        // Black's score with perfect play from move (60 - Depth)
+	Moves            []Position // Move sequence, passes are not recorded
+}
+
+func decodeWthorHeader(b []byte) WthorHeader {
+	// Decode the 16 byte header shared by all WTHOR files
+	// Integers are stored little endian
+	header := WthorHeader{
+		Created:   [3]int{int(b[0])*100 + int(b[1]), int(b[2]), int(b[3])},
+		Games:     int(binary.LittleEndian.Uint32(b[4:8])),
+		Records:   int(binary.LittleEndian.Uint16(b[8:10])),
+		Year:      int(binary.LittleEndian.Uint16(b[10:12])),
+		BoardSize: int(b[12]),
+		Depth:     int(b[14]),
+	}
+
+	// Older files leave the board size as 0 for the standard 8x8 board
+	if header.BoardSize == 0 {
+		header.BoardSize = 8
+	}
+	return header
+}
+
+type WthorReader struct {
+	r      *bufio.Reader
+	Header WthorHeader
+	read   int // Number of game records read so far
+}
+
+func NewWthorReader(r io.Reader) (*WthorReader, error) {
+	// Read the header of a .wtb file
+	// Game records are then read one at a time with Next
+	wr := &WthorReader{r: bufio.NewReader(r)}
+	b := make([]byte, wthorHeaderSize)
+	if _, err := io.ReadFull(wr.r, b); err != nil {
+		return nil, fmt.Errorf("reading wthor header: %v", err)
+	}
+	wr.Header = decodeWthorHeader(b)
+	if wr.Header.BoardSize != 8 {
+		return nil, fmt.Errorf("unsupported wthor board size %d", wr.Header.BoardSize)
+	}
+	return wr, nil
+}
+
+func (wr *WthorReader) Next() (WthorGame, error) {
+	// Read the next game record
+	// Returns io.EOF once all games in the file have been read
+	if wr.read >= wr.Header.Games {
+		return WthorGame{}, io.EOF
+	}
+	b := make([]byte, wthorGameSize)
+	if _, err := io.ReadFull(wr.r, b); err != nil {
+		return WthorGame{}, fmt.Errorf("reading wthor game %d: %v", wr.read, err)
+	}
+	wr.read++
+
+	game := WthorGame{
+		Year:             wr.Header.Year,
+		Tournament:       int(binary.LittleEndian.Uint16(b[0:2])),
+		Black:            int(binary.LittleEndian.Uint16(b[2:4])),
+		White:            int(binary.LittleEndian.Uint16(b[4:6])),
+		BlackScore:       int(b[6]),
+		TheoreticalScore: int(b[7]),
+		Moves:            []Position{},
+	}
+
+	// Moves are stored as 10*row + column, both starting from 1
+	// Example:
+	//     56 -> row 5, column 6 -> "F5"
+	// The sequence ends early with 0 when the game finished before 60 moves
+	for _, m := range b[8:] {
+		if m == 0 {
+			break
+		}
+		move := Position{int(m)/10 - 1, int(m)%10 - 1}
+		if move.i < 0 || move.i > 7 || move.j < 0 || move.j > 7 {
+			return game, fmt.Errorf("wthor game %d has invalid move byte %d", wr.read-1, m)
+		}
+		game.Moves = append(game.Moves, move)
+	}
+	return game, nil
+}
+
+func readWthorNames(path string, recordSize int) ([]string, error) {
+	// Read the names stored in WTHOR.JOU (players) or WTHOR.TRN (tournaments)
+	// Names are null padded and indexed by their position in the file
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	r := bufio.NewReader(f)
+	b := make([]byte, wthorHeaderSize)
+	if _, err := io.ReadFull(r, b); err != nil {
+		return nil, fmt.Errorf("reading %s header: %v", path, err)
+	}
+	header := decodeWthorHeader(b)
+	names := make([]string, 0, header.Records)
+	b = make([]byte, recordSize)
+	for i := 0; i < header.Records; i++ {
+		if _, err := io.ReadFull(r, b); err != nil {
+			return nil, fmt.Errorf("reading %s record %d: %v", path, i, err)
+		}
+		name := latin1ToUTF8(bytes.TrimRight(b, "\x00"))
+		names = append(names, strings.TrimSpace(name))
+	}
+	return names, nil
+}
+
+func (g WthorGame) Replay() (Board, error) {
+	// Replay the moves of a game through Board from the standard start position
+	// Returns an error at the first illegal move
+	game := newGame()
+	err := game.playMoves(g.Moves)
+	return game, err
+}
+
+func (g WthorGame) Notation() string {
+	// Move sequence as a single string of standard Othello notation
+	// Example:
+	//     "F5D6C3D3C4"
+	s := strings.Builder{}
+	for _, move := range g.Moves {
+		s.WriteString(move.Notation())
+	}
+	return s.String()
+}
+
+type wthorFilter struct {
+	player     string // Player name (substring) or index, either colour
+	tournament string // Tournament name (substring) or index
+	minYear    int
+	maxYear    int
+}
+
+func matchWthorName(query string, index int, names []string) bool {
+	// Match a query against an index in WTHOR.JOU / WTHOR.TRN
+	// The query is either the index itself or part of the name
+	if query == "" {
+		return true
+	}
+	if n, err := strconv.Atoi(query); err == nil {
+		return n == index
+	}
+	if index < len(names) {
+		return strings.Contains(strings.ToLower(names[index]), strings.ToLower(query))
+	}
+	return false
+}
+
+func (f wthorFilter) match(g WthorGame, players []string, tournaments []string) bool {
+	// Check if a game passes all filters
+	if f.minYear > 0 && g.Year < f.minYear {
+		return false
+	}
+	if f.maxYear > 0 && g.Year > f.maxYear {
+		return false
+	}
+	if !matchWthorName(f.tournament, g.Tournament, tournaments) {
+		return false
+	}
+	if f.player != "" &&
+		!matchWthorName(f.player, g.Black, players) &&
+		!matchWthorName(f.player, g.White, players) {
+		return false
+	}
+	return true
+}
+
+func latin1ToUTF8(b []byte) string {
+	// Names are stored in ISO-8859-1, where every byte is the code point
+	runes := make([]rune, len(b))
+	for i, c := range b {
+		runes[i] = rune(c)
+	}
+	return string(runes)
+}
+
+func wthorName(index int, names []string) string {
+	// Name for an index in WTHOR.JOU / WTHOR.TRN, or the index if unknown
+	if index < len(names) {
+		return names[index]
+	}
+	return strconv.Itoa(index)
+}
+
+func (g WthorGame) csvRecord(players []string, tournaments []string) []string {
+	// Row of the CSV export, names are looked up in WTHOR.JOU / WTHOR.TRN
+	return []string{
+		strconv.Itoa(g.Year),
+		wthorName(g.Tournament, tournaments),
+		wthorName(g.Black, players),
+		wthorName(g.White, players),
+		strconv.Itoa(g.BlackScore), strconv.Itoa(g.TheoreticalScore), g.Notation(),
+	}
+}
+
+func wthorCommand(args []string) error {
+	// Iterate through WTHOR .wtb files, filter games and export them
+	// Every game is replayed through Board to check that it is legal
+	// Example:
+	//     > reversi wthor -players WTHOR.JOU -player Tastet -out games.csv WTH_2018.wtb WTH_2019.wtb
+	fs := flag.NewFlagSet("wthor", flag.ExitOnError)
+	playersPath := fs.String("players", "", "path to WTHOR.JOU for player names")
+	tournamentsPath := fs.String("tournaments", "", "path to WTHOR.TRN for tournament names")
+	filter := wthorFilter{}
+	fs.StringVar(&filter.player, "player", "", "only games with this player (name or index)")
+	fs.StringVar(&filter.tournament, "tournament", "", "only games from this tournament (name or index)")
+	fs.IntVar(&filter.minYear, "min-year", 0, "only games played in or after this year")
+	fs.IntVar(&filter.maxYear, "max-year", 0, "only games played in or before this year")
+	out := fs.String("out", "", "export matching games to this file (default stdout)")
+	format := fs.String("format", "csv", "export format: csv or moves")
+	fs.Parse(args)
+	if fs.NArg() == 0 {
+		return errors.New("no .wtb files given")
+	}
+	if *format != "csv" && *format != "moves" {
+		return fmt.Errorf("unknown export format %q", *format)
+	}
+
+	players := []string{}
+	tournaments := []string{}
+	var err error
+	if *playersPath != "" {
+		if players, err = readWthorNames(*playersPath, wthorPlayerSize); err != nil {
+			return err
+		}
+	}
+	if *tournamentsPath != "" {
+		if tournaments, err = readWthorNames(*tournamentsPath, wthorTournamentSize); err != nil {
+			return err
+		}
+	}
+
+	w := os.Stdout
+	if *out != "" {
+		if w, err = os.Create(*out); err != nil {
+			return err
+		}
+		defer w.Close()
+	}
+	bw := bufio.NewWriter(w)
+	defer bw.Flush()
+	cw := csv.NewWriter(bw)
+	defer cw.Flush()
+	if *format == "csv" {
+		cw.Write([]string{"year", "tournament", "black", "white", "blackScore", "theoreticalScore", "moves"})
+	}
+
+	total, matched, illegal := 0, 0, 0
+	for _, path := range fs.Args() {
+		f, err := os.Open(path)
+		if err != nil {
+			return err
+		}
+		wr, err := NewWthorReader(f)
+		if err != nil {
+			f.Close()
+			return fmt.Errorf("%s: %v", path, err)
+		}
+		for {
+			g, err := wr.Next()
+			if err == io.EOF {
+				break
+			}
+			if err != nil {
+				f.Close()
+				return fmt.Errorf("%s: %v", path, err)
+			}
+			total++
+			if !filter.match(g, players, tournaments) {
+				continue
+			}
+			if _, err := g.Replay(); err != nil {
+				fmt.Fprintf(os.Stderr, "%s: skipping game %d: %v\n", path, wr.read-1, err)
+				illegal++
+				continue
+			}
+			matched++
+			if *format == "moves" {
+				fmt.Fprintln(bw, g.Notation())
+			} else {
+				cw.Write(g.csvRecord(players, tournaments))
+			}
+		}
+		f.Close()
+	}
+	fmt.Fprintf(os.Stderr, "Games: %d, Matched: %d, Illegal: %d\n", total, matched, illegal)
+	cw.Flush()
+	return cw.Error()
+}
@@ -0,0 +1,53 @@
+package main
+
+import (
+	"bytes"
+	"encoding/csv"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestWthorNames(t *testing.T) {
+	// Names are null padded ISO-8859-1 and are returned as UTF-8
+	b := make([]byte, wthorHeaderSize)
+	b[8] = 2
+	player := make([]byte, wthorPlayerSize)
+	copy(player, "L\xe9vy \"Bob\"")
+	b = append(b, player...)
+	player = make([]byte, wthorPlayerSize)
+	copy(player, "Tastet")
+	b = append(b, player...)
+	path := filepath.Join(t.TempDir(), "WTHOR.JOU")
+	if err := os.WriteFile(path, b, 0644); err != nil {
+		t.Fatal(err)
+	}
+	names, err := readWthorNames(path, wthorPlayerSize)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(names) != 2 || names[0] != "Lévy \"Bob\"" || names[1] != "Tastet" {
+		t.Errorf("names %q", names)
+	}
+}
+
+func TestWthorCSV(t *testing.T) {
+	// Names with quotes and commas survive a round trip through a CSV reader
+	players := []string{"Lévy \"Bob\"", "Tastet, Marc"}
+	g := WthorGame{Year: 2019, Tournament: 7, Black: 0, White: 1, BlackScore: 40, TheoreticalScore: 36,
+		Moves: []Position{{4, 5}, {5, 3}}}
+	buf := bytes.Buffer{}
+	w := csv.NewWriter(&buf)
+	w.Write(g.csvRecord(players, nil))
+	w.Flush()
+	record, err := csv.NewReader(&buf).Read()
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"2019", "7", "Lévy \"Bob\"", "Tastet, Marc", "40", "36", "F5D6"}
+	for k := range want {
+		if record[k] != want[k] {
+			t.Errorf("field %d is %q, want %q", k, record[k], want[k])
+		}
+	}
+}